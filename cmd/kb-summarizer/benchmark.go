@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/rancher/security-scan/pkg/kb-summarizer/catalog"
+	"github.com/rancher/security-scan/pkg/kb-summarizer/diff"
+	"github.com/rancher/security-scan/pkg/kb-summarizer/eval"
+	"github.com/rancher/security-scan/pkg/kb-summarizer/helpers"
+	"github.com/rancher/security-scan/pkg/kb-summarizer/summarizer"
+	cli "github.com/urfave/cli/v3"
+)
+
+const (
+	NoCrosswalkFlag = "no-crosswalk"
+)
+
+// benchmarkFlags are the flags of the commands inspecting the benchmarks of
+// the controls directory.
+func benchmarkFlags(extra ...cli.Flag) []cli.Flag {
+	return helperFlags(append([]cli.Flag{
+		&cli.StringFlag{
+			Name:  ControlsDirFlag,
+			Value: summarizer.DefaultControlsDirectory,
+		},
+	}, extra...)...)
+}
+
+func benchmarkDiffCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "benchmark-diff",
+		Usage:     "report the checks added, removed, renumbered, moved or changed from a benchmark to another",
+		ArgsUsage: "<benchmark> <benchmark>",
+		Flags: benchmarkFlags(
+			&cli.BoolFlag{
+				Name:  NoCrosswalkFlag,
+				Usage: "pair the checks by text even when the crosswalk files link the benchmarks",
+			},
+		),
+		Action: func(_ context.Context, c *cli.Command) error {
+			if c.Args().Len() != 2 {
+				return fmt.Errorf("expected two benchmarks as arguments")
+			}
+			controlsDir := c.String(ControlsDirFlag)
+			from, err := summarizer.LoadBenchmark(controlsDir, c.Args().Get(0))
+			if err != nil {
+				return err
+			}
+			to, err := summarizer.LoadBenchmark(controlsDir, c.Args().Get(1))
+			if err != nil {
+				return err
+			}
+			var crosswalk *summarizer.Crosswalk
+			if !c.Bool(NoCrosswalkFlag) {
+				if crosswalk, err = summarizer.FindCrosswalk(controlsDir, from.Name, to.Name); err != nil {
+					return err
+				}
+			}
+			return helpers.Print(os.Stdout, c.String(HelperOutputFlag), diff.Compare(from, to, crosswalk))
+		},
+	}
+}
+
+func benchmarksCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "benchmarks",
+		Usage: "describe the benchmarks of the controls directory",
+		Commands: []*cli.Command{
+			{
+				Name:  "list",
+				Usage: "list the benchmarks with their kubernetes versions, targets and check counts",
+				Flags: benchmarkFlags(),
+				Action: func(_ context.Context, c *cli.Command) error {
+					l, err := catalog.Load(c.String(ControlsDirFlag))
+					if err != nil {
+						return err
+					}
+					return helpers.Print(os.Stdout, c.String(HelperOutputFlag), l)
+				},
+			},
+			{
+				Name:      "test",
+				Usage:     "evaluate the fixtures of the benchmarks, all of them when none is given",
+				ArgsUsage: "[<benchmark>...]",
+				Flags:     benchmarkFlags(),
+				Action: func(_ context.Context, c *cli.Command) error {
+					controlsDir := c.String(ControlsDirFlag)
+					benchmarks := c.Args().Slice()
+					if len(benchmarks) == 0 {
+						var err error
+						if benchmarks, err = summarizer.Benchmarks(controlsDir); err != nil {
+							return err
+						}
+					}
+					r, err := eval.RunFixtures(controlsDir, benchmarks)
+					if err != nil {
+						return err
+					}
+					if err := helpers.Print(os.Stdout, c.String(HelperOutputFlag), r); err != nil {
+						return err
+					}
+					if r.Failed > 0 {
+						return fmt.Errorf("%v fixtures failed", r.Failed)
+					}
+					return nil
+				},
+			},
+			{
+				Name:      "show",
+				Usage:     "show the targets of a benchmark and their check counts",
+				ArgsUsage: "<benchmark>",
+				Flags:     benchmarkFlags(),
+				Action: func(_ context.Context, c *cli.Command) error {
+					if c.Args().Len() != 1 {
+						return fmt.Errorf("expected a benchmark as argument")
+					}
+					b, err := catalog.Show(c.String(ControlsDirFlag), c.Args().First())
+					if err != nil {
+						return err
+					}
+					return helpers.Print(os.Stdout, c.String(HelperOutputFlag), b)
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/rancher/security-scan/pkg/kb-summarizer/eval"
+	"github.com/rancher/security-scan/pkg/kb-summarizer/helpers"
+	"github.com/rancher/security-scan/pkg/kb-summarizer/summarizer"
+	cli "github.com/urfave/cli/v3"
+)
+
+const (
+	AuditOutputFlag       = "audit-output"
+	AuditConfigOutputFlag = "audit-config-output"
+	AuditEnvOutputFlag    = "audit-env-output"
+)
+
+func evalCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "eval",
+		Usage: "evaluate the tests of a check against captured audit outputs, without running a scan",
+		Flags: benchmarkFlags(
+			&cli.StringFlag{
+				Name:     BenchmarkFlag,
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:     CheckFlag,
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:     AuditOutputFlag,
+				Usage:    "file with the output of the audit command",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:  AuditConfigOutputFlag,
+				Usage: "file with the output of the audit_config command",
+			},
+			&cli.StringFlag{
+				Name:  AuditEnvOutputFlag,
+				Usage: "file with the output of the audit_env command",
+			},
+		),
+		Action: func(_ context.Context, c *cli.Command) error {
+			b, err := summarizer.LoadBenchmark(c.String(ControlsDirFlag), c.String(BenchmarkFlag))
+			if err != nil {
+				return err
+			}
+			check := b.Check(c.String(CheckFlag))
+			if check == nil {
+				return fmt.Errorf("check %v is not part of benchmark %v", c.String(CheckFlag), b.Name)
+			}
+			outputs := &eval.Outputs{}
+			for flag, output := range map[string]*string{
+				AuditOutputFlag:       &outputs.Audit,
+				AuditConfigOutputFlag: &outputs.AuditConfig,
+				AuditEnvOutputFlag:    &outputs.AuditEnv,
+			} {
+				if *output, err = readOptionalFile(c.String(flag)); err != nil {
+					return err
+				}
+			}
+			r, err := eval.Evaluate(b.Name, check, outputs)
+			if err != nil {
+				return err
+			}
+			return helpers.Print(os.Stdout, c.String(HelperOutputFlag), r)
+		},
+	}
+}
+
+// readOptionalFile returns the content of the file, nothing when no file is
+// given.
+func readOptionalFile(file string) (string, error) {
+	if file == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(filepath.Clean(file))
+	if err != nil {
+		return "", fmt.Errorf("error reading file %v: %w", file, err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/rancher/security-scan/pkg/kb-summarizer/explain"
+	"github.com/rancher/security-scan/pkg/kb-summarizer/helpers"
+	"github.com/rancher/security-scan/pkg/kb-summarizer/report"
+	"github.com/rancher/security-scan/pkg/kb-summarizer/summarizer"
+	cli "github.com/urfave/cli/v3"
+)
+
+const (
+	BenchmarkFlag = "benchmark"
+	ReportFlag    = "report"
+)
+
+func explainCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "explain",
+		Usage:     "print the control definition of a check and, given a report, its state and actual value on each node",
+		ArgsUsage: "<check id>",
+		Flags: benchmarkFlags(
+			&cli.StringFlag{
+				Name:  BenchmarkFlag,
+				Usage: "benchmark of the check, defaults to the benchmark of the report",
+			},
+			&cli.StringFlag{
+				Name:  ReportFlag,
+				Usage: "report of the summarizer, or the one of the cluster scan report",
+			},
+		),
+		Action: func(_ context.Context, c *cli.Command) error {
+			if c.Args().Len() != 1 {
+				return fmt.Errorf("expected a check id as argument")
+			}
+			var r *report.Report
+			if reportFile := c.String(ReportFlag); reportFile != "" {
+				data, err := os.ReadFile(filepath.Clean(reportFile))
+				if err != nil {
+					return fmt.Errorf("error reading file %v: %w", reportFile, err)
+				}
+				if r, err = report.Parse(data); err != nil {
+					return err
+				}
+			}
+			benchmark := c.String(BenchmarkFlag)
+			if benchmark == "" && r != nil {
+				benchmark = r.Version
+			}
+			if benchmark == "" {
+				return fmt.Errorf("expected --%v or --%v", BenchmarkFlag, ReportFlag)
+			}
+			b, err := summarizer.LoadBenchmark(c.String(ControlsDirFlag), benchmark)
+			if err != nil {
+				return err
+			}
+			e, err := explain.Explain(b, c.Args().First(), r)
+			if err != nil {
+				return err
+			}
+			return helpers.Print(os.Stdout, c.String(HelperOutputFlag), e)
+		},
+	}
+}
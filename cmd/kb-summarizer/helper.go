@@ -0,0 +1,564 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/rancher/security-scan/pkg/kb-summarizer/helpers"
+	"github.com/rancher/security-scan/pkg/kb-summarizer/helpers/auditpolicy"
+	"github.com/rancher/security-scan/pkg/kb-summarizer/helpers/components"
+	"github.com/rancher/security-scan/pkg/kb-summarizer/helpers/defaultusage"
+	"github.com/rancher/security-scan/pkg/kb-summarizer/helpers/encryption"
+	"github.com/rancher/security-scan/pkg/kb-summarizer/helpers/files"
+	"github.com/rancher/security-scan/pkg/kb-summarizer/helpers/kube"
+	"github.com/rancher/security-scan/pkg/kb-summarizer/helpers/kubelet"
+	"github.com/rancher/security-scan/pkg/kb-summarizer/helpers/networkpolicy"
+	"github.com/rancher/security-scan/pkg/kb-summarizer/helpers/pki"
+	"github.com/rancher/security-scan/pkg/kb-summarizer/helpers/podsecurity"
+	"github.com/rancher/security-scan/pkg/kb-summarizer/helpers/rbac"
+	"github.com/rancher/security-scan/pkg/kb-summarizer/helpers/secrets"
+	cli "github.com/urfave/cli/v3"
+)
+
+const (
+	HelperOutputFlag    = "output"
+	HelperRootFlag      = "root"
+	HelperProcDirFlag   = "proc-dir"
+	HelperCAFileFlag    = "ca-file"
+	HelperConfigFlag    = "config"
+	HelperResources     = "resources"
+	KubeconfigFlag      = "kubeconfig"
+	DistroFlag          = "distro"
+	NamespacesFlag      = "namespaces"
+	ExcludeNSFlag       = "exclude-namespaces"
+	CNIDirFlag          = "cni-dir"
+	ExemptNSFlag        = "exempt-namespaces"
+	ExemptBindingFlag   = "exempt-bindings"
+	NamespaceFlag       = "namespace"
+	ExemptFlag          = "exempt"
+	CheckFlag           = "check"
+	AllowFlag           = "allow"
+	IncludeBootstrap    = "include-bootstrap"
+	LevelFlag           = "level"
+	ControlFlag         = "control"
+	ArgsFlag            = "args"
+	KubeBenchConfigFlag = "kube-bench-config"
+	DistroConfigFlag    = "distro-config"
+	DirFlag             = "dir"
+	ExpiryDaysFlag      = "expiry-days"
+	JournalUnitFlag     = "journal-unit"
+	KubeletCAFileEnv    = "kubeletcafile"
+)
+
+func helperCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "helper",
+		Usage: "run an audit helper for kube-bench controls",
+		Before: func(ctx context.Context, _ *cli.Command) (context.Context, error) {
+			// The output of helpers is parsed by kube-bench, keep it clean.
+			slog.SetLogLoggerLevel(slog.LevelError)
+			return ctx, nil
+		},
+		Commands: []*cli.Command{
+			{
+				Name:      "files-permissions",
+				Usage:     "check that files are 644 or more restrictive, or the given permission",
+				ArgsUsage: "<directory|pattern> [permission]",
+				Flags:     hostHelperFlags(),
+				Action: func(_ context.Context, c *cli.Command) error {
+					r, err := files.CheckPermissions(c.String(HelperRootFlag), c.Args().Get(0), c.Args().Get(1))
+					return printBoolHelperResult(c, r, err)
+				},
+			},
+			{
+				Name:      "files-owner",
+				Usage:     "check that a directory and its files are owned by root:root",
+				ArgsUsage: "<directory>",
+				Flags:     hostHelperFlags(),
+				Action: func(_ context.Context, c *cli.Command) error {
+					r, err := files.CheckOwnerInDir(c.String(HelperRootFlag), c.Args().First())
+					return printBoolHelperResult(c, r, err)
+				},
+			},
+			{
+				Name:  "cafile-permissions",
+				Usage: "print the permissions of the kubelet client CA file",
+				Flags: cafileFlags(),
+				Action: func(_ context.Context, c *cli.Command) error {
+					info, err := statKubeletCAFile(c)
+					if err != nil {
+						return printHelperResult(c, nil, err)
+					}
+					return printHelperResult(c, &files.ModeResult{Info: info}, nil)
+				},
+			},
+			{
+				Name:  "cafile-ownership",
+				Usage: "print the ownership of the kubelet client CA file",
+				Flags: cafileFlags(),
+				Action: func(_ context.Context, c *cli.Command) error {
+					info, err := statKubeletCAFile(c)
+					if err != nil {
+						return printHelperResult(c, nil, err)
+					}
+					return printHelperResult(c, &files.OwnerResult{Info: info}, nil)
+				},
+			},
+			{
+				Name:      "encryption-provider-config",
+				Usage:     "check that the apiserver encrypts resources with an approved provider",
+				ArgsUsage: "[approved provider...]",
+				Flags: hostHelperFlags(
+					procDirFlag(),
+					&cli.StringFlag{
+						Name:  HelperConfigFlag,
+						Usage: "EncryptionConfiguration file, found from the kube-apiserver flags when not set",
+						Value: "",
+					},
+					&cli.StringSliceFlag{
+						Name:  HelperResources,
+						Usage: "resources that must be encrypted",
+						Value: encryption.DefaultResources,
+					},
+				),
+				Action: func(_ context.Context, c *cli.Command) error {
+					r, err := checkEncryptionProviderConfig(c)
+					return printBoolHelperResult(c, r, err)
+				},
+			},
+			{
+				Name:  "network-policies",
+				Usage: "check that every namespace has at least one NetworkPolicy",
+				Flags: helperFlags(
+					kubeconfigFlag(),
+					&cli.StringFlag{
+						Name:  DistroFlag,
+						Usage: "one of: generic, rke2, k3s",
+						Value: networkpolicy.DistroGeneric,
+					},
+					&cli.StringSliceFlag{
+						Name:  NamespacesFlag,
+						Usage: "namespaces to check, all when not set",
+					},
+					&cli.StringSliceFlag{
+						Name:  ExcludeNSFlag,
+						Usage: "namespace patterns to skip, e.g. cattle-*",
+					},
+				),
+				Action: func(ctx context.Context, c *cli.Command) error {
+					client, err := kube.NewClient(c.String(KubeconfigFlag))
+					if err != nil {
+						return printBoolHelperResult(c, nil, err)
+					}
+					r, err := networkpolicy.Check(ctx, client, networkpolicy.Options{
+						Distro:     c.String(DistroFlag),
+						Namespaces: c.StringSlice(NamespacesFlag),
+						Exclude:    c.StringSlice(ExcludeNSFlag),
+					})
+					return printBoolHelperResult(c, r, err)
+				},
+			},
+			{
+				Name:  "cni-network-policy-support",
+				Usage: "check that a CNI enforcing network policies is configured",
+				Flags: hostHelperFlags(
+					&cli.StringFlag{
+						Name:  CNIDirFlag,
+						Value: networkpolicy.DefaultCNIDirectory,
+					},
+				),
+				Action: func(_ context.Context, c *cli.Command) error {
+					r, err := networkpolicy.CheckCNI(c.String(HelperRootFlag), c.String(CNIDirFlag))
+					return printBoolHelperResult(c, r, err)
+				},
+			},
+			{
+				Name:  "default-service-accounts",
+				Usage: "check that default service accounts are not actively used",
+				Flags: helperFlags(
+					kubeconfigFlag(),
+					&cli.StringSliceFlag{
+						Name:  ExemptNSFlag,
+						Usage: "namespace patterns whose default service account is not checked",
+					},
+					&cli.StringSliceFlag{
+						Name:  ExemptBindingFlag,
+						Usage: "binding name patterns allowed to grant permissions to default service accounts",
+					},
+				),
+				Action: func(ctx context.Context, c *cli.Command) error {
+					client, err := kube.NewClient(c.String(KubeconfigFlag))
+					if err != nil {
+						return printBoolHelperResult(c, nil, err)
+					}
+					r, err := defaultusage.CheckServiceAccounts(ctx, client, defaultusage.ServiceAccountOptions{
+						ExemptNamespaces: c.StringSlice(ExemptNSFlag),
+						ExemptBindings:   c.StringSlice(ExemptBindingFlag),
+					})
+					return printBoolHelperResult(c, r, err)
+				},
+			},
+			{
+				Name:  "default-namespace",
+				Usage: "check that no workloads run in the default namespace",
+				Flags: helperFlags(
+					kubeconfigFlag(),
+					&cli.StringFlag{
+						Name:  NamespaceFlag,
+						Value: defaultusage.DefaultNamespace,
+					},
+					&cli.StringSliceFlag{
+						Name:  ExemptFlag,
+						Usage: "Kind/name patterns of objects allowed in the namespace",
+						Value: defaultusage.DefaultNamespaceExemptions,
+					},
+				),
+				Action: func(ctx context.Context, c *cli.Command) error {
+					client, err := kube.NewClient(c.String(KubeconfigFlag))
+					if err != nil {
+						return printBoolHelperResult(c, nil, err)
+					}
+					r, err := defaultusage.CheckNamespace(ctx, client, defaultusage.NamespaceOptions{
+						Namespace:  c.String(NamespaceFlag),
+						Exemptions: c.StringSlice(ExemptFlag),
+					})
+					return printBoolHelperResult(c, r, err)
+				},
+			},
+			{
+				Name:  "rbac",
+				Usage: "report subjects with cluster-admin, wildcard, secrets or pod creation rights",
+				Flags: helperFlags(
+					kubeconfigFlag(),
+					&cli.StringSliceFlag{
+						Name:  CheckFlag,
+						Usage: "checks to run, one or more of: cluster-admin, wildcards, secrets, create-pods",
+						Value: rbac.Checks,
+					},
+					&cli.StringSliceFlag{
+						Name:  AllowFlag,
+						Usage: "allowed name, Kind/name or Kind/namespace/name patterns of the bindings, subjects or roles",
+					},
+					&cli.BoolFlag{
+						Name:  IncludeBootstrap,
+						Usage: "also report the default bindings created by Kubernetes",
+					},
+				),
+				Action: func(ctx context.Context, c *cli.Command) error {
+					client, err := kube.NewClient(c.String(KubeconfigFlag))
+					if err != nil {
+						return printHelperResult(c, nil, err)
+					}
+					snapshot, err := rbac.Load(ctx, client)
+					if err != nil {
+						return printHelperResult(c, nil, err)
+					}
+					r, err := rbac.Analyze(snapshot, rbac.Options{
+						Checks:           c.StringSlice(CheckFlag),
+						Allow:            c.StringSlice(AllowFlag),
+						IncludeBootstrap: c.Bool(IncludeBootstrap),
+					})
+					return printHelperResult(c, r, err)
+				},
+			},
+			{
+				Name:  "pod-security",
+				Usage: "evaluate workloads against the baseline or restricted Pod Security Standards",
+				Flags: helperFlags(
+					kubeconfigFlag(),
+					&cli.StringFlag{
+						Name:  LevelFlag,
+						Usage: "one of: baseline, restricted",
+						Value: podsecurity.LevelRestricted,
+					},
+					&cli.StringSliceFlag{
+						Name:  ControlFlag,
+						Usage: "controls to evaluate, all the controls of the level when not set",
+					},
+					&cli.StringSliceFlag{
+						Name:  ExemptNSFlag,
+						Usage: "namespace patterns that are not evaluated",
+					},
+				),
+				Action: func(ctx context.Context, c *cli.Command) error {
+					client, err := kube.NewClient(c.String(KubeconfigFlag))
+					if err != nil {
+						return printHelperResult(c, nil, err)
+					}
+					workloads, err := kube.ListWorkloads(ctx, client)
+					if err != nil {
+						return printHelperResult(c, nil, err)
+					}
+					r, err := podsecurity.Evaluate(workloads, podsecurity.Options{
+						Level:            c.String(LevelFlag),
+						Controls:         c.StringSlice(ControlFlag),
+						ExemptNamespaces: c.StringSlice(ExemptNSFlag),
+					})
+					return printHelperResult(c, r, err)
+				},
+			},
+			{
+				Name:  "secrets-env",
+				Usage: "list the workloads consuming Secrets as environment variables",
+				Flags: helperFlags(
+					kubeconfigFlag(),
+					&cli.StringFlag{
+						Name:  DistroFlag,
+						Usage: "one of: generic, rke2, k3s",
+						Value: secrets.DistroGeneric,
+					},
+					&cli.StringSliceFlag{
+						Name:  ExemptNSFlag,
+						Usage: "namespace patterns that are not scanned, on top of the distribution defaults",
+						Value: secrets.DefaultExemptNamespaces,
+					},
+				),
+				Action: func(ctx context.Context, c *cli.Command) error {
+					client, err := kube.NewClient(c.String(KubeconfigFlag))
+					if err != nil {
+						return printHelperResult(c, nil, err)
+					}
+					workloads, err := kube.ListWorkloads(ctx, client)
+					if err != nil {
+						return printHelperResult(c, nil, err)
+					}
+					r, err := secrets.Scan(workloads, secrets.Options{
+						Distro:           c.String(DistroFlag),
+						ExemptNamespaces: c.StringSlice(ExemptNSFlag),
+					})
+					return printHelperResult(c, r, err)
+				},
+			},
+			{
+				Name:  "kubelet-config",
+				Usage: "print the effective kubelet configuration as key=value lines",
+				Flags: hostHelperFlags(
+					procDirFlag(),
+					&cli.StringFlag{
+						Name:  HelperConfigFlag,
+						Usage: "KubeletConfiguration file, read when the kubelet runs without --config",
+						Value: kubelet.DefaultConfigFile,
+					},
+					&cli.StringFlag{
+						Name:  ArgsFlag,
+						Usage: "kubelet command line to use instead of the running process, e.g. for k3s",
+					},
+				),
+				Action: func(_ context.Context, c *cli.Command) error {
+					r, err := kubelet.Resolve(kubelet.Options{
+						Root:       c.String(HelperRootFlag),
+						ProcDir:    c.String(HelperProcDirFlag),
+						Args:       strings.Fields(c.String(ArgsFlag)),
+						ConfigFile: c.String(HelperConfigFlag),
+					})
+					return printHelperResult(c, r, err)
+				},
+			},
+			{
+				Name:  "audit-policy",
+				Usage: "check that the audit policy covers secrets, configmaps, tokenreviews and pod exec",
+				Flags: hostHelperFlags(
+					procDirFlag(),
+					&cli.StringFlag{
+						Name:  HelperConfigFlag,
+						Usage: "audit Policy file, found from the kube-apiserver arguments when not set",
+					},
+				),
+				Action: func(_ context.Context, c *cli.Command) error {
+					r, err := checkAuditPolicy(c)
+					return printHelperResult(c, r, err)
+				},
+			},
+			{
+				Name:  "pki",
+				Usage: "inspect the expiry, keys, SANs and CA reuse of the control plane certificates",
+				Flags: hostHelperFlags(
+					&cli.StringSliceFlag{
+						Name:  DirFlag,
+						Usage: "PKI directories, the missing ones are skipped",
+						Value: pki.DefaultDirs,
+					},
+					&cli.StringSliceFlag{
+						Name:  CheckFlag,
+						Usage: "checks to run, all when not set, any of: expiry, key, san, ca-reuse. The keys are only listed by the key check",
+					},
+					&cli.IntFlag{
+						Name:  ExpiryDaysFlag,
+						Usage: "report the certificates expiring within this many days",
+						Value: pki.DefaultExpiryDays,
+					},
+				),
+				Action: func(_ context.Context, c *cli.Command) error {
+					r, err := pki.Inspect(pki.Options{
+						Root:       c.String(HelperRootFlag),
+						Dirs:       c.StringSlice(DirFlag),
+						Checks:     c.StringSlice(CheckFlag),
+						ExpiryDays: c.Int(ExpiryDaysFlag),
+					})
+					return printHelperResult(c, r, err)
+				},
+			},
+			{
+				Name:      "component-args",
+				Usage:     "print the effective arguments of a control plane component as --name=value lines",
+				ArgsUsage: "<apiserver|scheduler|controllermanager|etcd>",
+				Flags: hostHelperFlags(
+					procDirFlag(),
+					&cli.StringFlag{
+						Name:  ArgsFlag,
+						Usage: "component command line to use instead of the running process, e.g. for k3s",
+					},
+					&cli.StringFlag{
+						Name:  KubeBenchConfigFlag,
+						Usage: "kube-bench configuration listing the static pod manifests",
+						Value: components.DefaultKubeBenchConfig,
+					},
+					&cli.StringSliceFlag{
+						Name:  DistroConfigFlag,
+						Usage: "RKE2/K3s configuration files",
+						Value: components.DefaultDistroConfigs,
+					},
+					&cli.StringFlag{
+						Name:  JournalUnitFlag,
+						Usage: "unit whose journal logs the component command line when no process runs it, empty to disable",
+						Value: components.DefaultJournalUnit,
+					},
+				),
+				Action: func(_ context.Context, c *cli.Command) error {
+					if c.Args().Len() != 1 {
+						return fmt.Errorf("expected exactly one component, got %d", c.Args().Len())
+					}
+					r, err := components.Resolve(c.Args().First(), components.Options{
+						Root:            c.String(HelperRootFlag),
+						ProcDir:         c.String(HelperProcDirFlag),
+						Args:            strings.Fields(c.String(ArgsFlag)),
+						KubeBenchConfig: c.String(KubeBenchConfigFlag),
+						DistroConfigs:   c.StringSlice(DistroConfigFlag),
+						JournalUnit:     c.String(JournalUnitFlag),
+					})
+					return printHelperResult(c, r, err)
+				},
+			},
+		},
+	}
+}
+
+func helperFlags(extra ...cli.Flag) []cli.Flag {
+	return append([]cli.Flag{
+		&cli.StringFlag{
+			Name:  HelperOutputFlag,
+			Usage: "output format, one of: text, json",
+			Value: helpers.OutputText,
+		},
+	}, extra...)
+}
+
+// hostHelperFlags are the flags of helpers that inspect the host filesystem.
+func hostHelperFlags(extra ...cli.Flag) []cli.Flag {
+	return helperFlags(append([]cli.Flag{
+		&cli.StringFlag{
+			Name:  HelperRootFlag,
+			Usage: "path where the host filesystem is mounted, e.g. /node",
+			Value: "",
+		},
+	}, extra...)...)
+}
+
+func cafileFlags() []cli.Flag {
+	return hostHelperFlags(
+		procDirFlag(),
+		&cli.StringFlag{
+			Name:    HelperCAFileFlag,
+			Usage:   "CA file to use when the kubelet does not set --client-ca-file",
+			Sources: cli.EnvVars(KubeletCAFileEnv),
+			Value:   "",
+		},
+	)
+}
+
+func kubeconfigFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:  KubeconfigFlag,
+		Usage: "kubeconfig file, the in-cluster config is used when not set",
+		Value: "",
+	}
+}
+
+func procDirFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:  HelperProcDirFlag,
+		Value: helpers.DefaultProcDirectory,
+	}
+}
+
+func statKubeletCAFile(c *cli.Command) (*files.Info, error) {
+	caFile, err := files.KubeletCAFile(c.String(HelperRootFlag), c.String(HelperProcDirFlag), c.String(HelperCAFileFlag))
+	if err != nil {
+		return nil, err
+	}
+	return files.Stat(caFile)
+}
+
+func checkEncryptionProviderConfig(c *cli.Command) (*encryption.Result, error) {
+	root := c.String(HelperRootFlag)
+	configFile := helpers.HostPath(root, c.String(HelperConfigFlag))
+	if configFile == "" {
+		var err error
+		if configFile, err = encryption.ConfigFile(root, c.String(HelperProcDirFlag)); err != nil {
+			return nil, err
+		}
+	}
+	config, err := encryption.Load(configFile)
+	if err != nil {
+		return nil, err
+	}
+	r := encryption.Analyze(config, c.StringSlice(HelperResources), c.Args().Slice())
+	r.ConfigFile = configFile
+	return r, nil
+}
+
+func checkAuditPolicy(c *cli.Command) (*auditpolicy.Result, error) {
+	root := c.String(HelperRootFlag)
+	policyFile := helpers.HostPath(root, c.String(HelperConfigFlag))
+	if policyFile == "" {
+		var err error
+		if policyFile, err = auditpolicy.PolicyFile(root, c.String(HelperProcDirFlag)); err != nil {
+			return nil, err
+		}
+	}
+	policy, err := auditpolicy.Load(policyFile)
+	if err != nil {
+		return nil, err
+	}
+	r := auditpolicy.Analyze(policy)
+	r.PolicyFile = policyFile
+	return r, nil
+}
+
+// printHelperResult prints the result of a helper. Errors print nothing, as
+// the replaced scripts did, e.g. when the kubelet CA file is missing, and are
+// returned so that kube-bench reports them as the reason of the check.
+func printHelperResult(c *cli.Command, r helpers.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	return helpers.Print(c.Root().Writer, c.String(HelperOutputFlag), r)
+}
+
+// printBoolHelperResult prints the result of a helper replacing a script that
+// printed true or false. In text mode errors are reported as "false", which is
+// what those scripts printed, so that control files keep working unchanged.
+func printBoolHelperResult(c *cli.Command, r helpers.Result, err error) error {
+	format := c.String(HelperOutputFlag)
+	if err != nil {
+		if format == helpers.OutputJSON {
+			return err
+		}
+		slog.Error("helper failed", slog.String("helper", c.Name), slog.Any("error", err))
+		_, err = fmt.Fprintln(c.Root().Writer, helpers.BoolText(false))
+		return err
+	}
+	return helpers.Print(c.Root().Writer, format, r)
+}
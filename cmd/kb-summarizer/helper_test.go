@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	cli "github.com/urfave/cli/v3"
+)
+
+func runHelper(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	var out bytes.Buffer
+	app := &cli.Command{
+		Name:     "kb-summarizer",
+		Writer:   &out,
+		Commands: []*cli.Command{helperCommand()},
+	}
+	err := app.Run(context.Background(), append([]string{"kb-summarizer", "helper"}, args...))
+	return out.String(), err
+}
+
+func TestHelper_missingCAFile(t *testing.T) {
+	t.Setenv(KubeletCAFileEnv, "")
+	procDir := t.TempDir()
+	caFile := filepath.Join(t.TempDir(), "client-ca.crt")
+
+	for _, helper := range []string{"cafile-permissions", "cafile-ownership"} {
+		t.Run(helper, func(t *testing.T) {
+			out, err := runHelper(t, helper, "--proc-dir", procDir, "--ca-file", caFile)
+			assert.ErrorIs(t, err, os.ErrNotExist)
+			assert.Empty(t, out)
+		})
+	}
+
+	require.Nil(t, os.WriteFile(caFile, []byte{}, 0600))
+	out, err := runHelper(t, "cafile-permissions", "--proc-dir", procDir, "--ca-file", caFile)
+	require.Nil(t, err)
+	assert.Equal(t, "permissions=600\n", out)
+}
+
+func TestHelper_boolErrors(t *testing.T) {
+	// the replaced script printed false on a missing directory
+	out, err := runHelper(t, "files-owner", filepath.Join(t.TempDir(), "missing"))
+	require.Nil(t, err)
+	assert.Equal(t, "false\n", out)
+
+	_, err = runHelper(t, "files-owner", "--output", "json", filepath.Join(t.TempDir(), "missing"))
+	assert.NotNil(t, err)
+}
@@ -6,6 +6,7 @@ import (
 	"log/slog"
 	"os"
 
+	"github.com/rancher/security-scan/pkg/kb-summarizer/eval"
 	"github.com/rancher/security-scan/pkg/kb-summarizer/summarizer"
 	cli "github.com/urfave/cli/v3"
 )
@@ -24,6 +25,9 @@ const (
 	DefaultSkipConfigFileEnvVar   = "DEFAULT_SKIP_CONFIG_FILE"
 	NotApplicableConfigFileFlag   = "not-applicable-config-file"
 	NotApplicableConfigFileEnvVar = "NOT_APPLICABLE_CONFIG_FILE"
+	StrictSkipConfigFlag          = "strict-skip-config"
+	StrictSkipConfigEnvVar        = "STRICT_SKIP_CONFIG"
+	RegradeFlag                   = "regrade"
 )
 
 var (
@@ -79,8 +83,25 @@ func main() {
 			&cli.BoolFlag{
 				Name: FailuresOnlyFlag,
 			},
+			&cli.BoolFlag{
+				Name:    StrictSkipConfigFlag,
+				Sources: cli.EnvVars(StrictSkipConfigEnvVar),
+				Usage:   "fail when the skip configs name checks that are not part of the benchmark",
+			},
+			&cli.BoolFlag{
+				Name:  RegradeFlag,
+				Usage: "grade the results of the input directory again against the controls, keeping the results of the checks whose audit changed",
+			},
 		},
 		Action: run,
+		Commands: []*cli.Command{
+			helperCommand(),
+			migrateSkipCommand(),
+			benchmarkDiffCommand(),
+			benchmarksCommand(),
+			explainCommand(),
+			evalCommand(),
+		},
 	}
 
 	if err := app.Run(context.TODO(), os.Args); err != nil {
@@ -133,6 +154,14 @@ func run(ctx context.Context, c *cli.Command) error {
 	if err != nil {
 		return fmt.Errorf("error creating summarizer: %w", err)
 	}
+	if c.Bool(RegradeFlag) {
+		s.Regrader = eval.Regrader{}
+	}
+	if c.Bool(StrictSkipConfigFlag) {
+		if err := s.CheckSkipConfigs(); err != nil {
+			return err
+		}
+	}
 	if err := s.Summarize(); err != nil {
 		return fmt.Errorf("error summarizing: %w", err)
 	}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/rancher/security-scan/pkg/kb-summarizer/summarizer"
+	cli "github.com/urfave/cli/v3"
+)
+
+const (
+	FromFlag   = "from"
+	ToFlag     = "to"
+	OutputFlag = "output-file"
+)
+
+func migrateSkipCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "migrate-skip",
+		Usage:     "add the exceptions of a benchmark of a user skip config as exceptions of a later benchmark, renumbered with the crosswalk files",
+		ArgsUsage: "<user skip config file>",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  ControlsDirFlag,
+				Value: summarizer.DefaultControlsDirectory,
+			},
+			&cli.StringFlag{
+				Name:     FromFlag,
+				Usage:    "benchmark the exceptions were written for",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:     ToFlag,
+				Usage:    "benchmark to migrate the exceptions to",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:  OutputFlag,
+				Usage: "file to write the migrated config to, stdout when empty",
+			},
+		},
+		Action: func(_ context.Context, c *cli.Command) error {
+			if c.Args().Len() != 1 {
+				return fmt.Errorf("expected the user skip config file as the only argument")
+			}
+			data, err := os.ReadFile(filepath.Clean(c.Args().First()))
+			if err != nil {
+				return fmt.Errorf("error reading user skip config: %w", err)
+			}
+			out, err := summarizer.MigrateUserSkipConfig(c.String(ControlsDirFlag), c.String(FromFlag), c.String(ToFlag), data)
+			if err != nil {
+				return err
+			}
+			if c.String(OutputFlag) == "" {
+				_, err = os.Stdout.Write(out)
+				return err
+			}
+			return os.WriteFile(filepath.Clean(c.String(OutputFlag)), out, 0o600)
+		},
+	}
+}
@@ -1,6 +1,5 @@
 #!/usr/bin/env bash
 
-CAFILE=$(ps -ef | grep kubelet | grep -v apiserver | grep -- --client-ca-file= | awk -F '--client-ca-file=' '{print $2}' | awk '{print $1}')
-CAFILE=/node$CAFILE
-if test -z $CAFILE; then CAFILE=$kubeletcafile; fi
-if test -e $CAFILE; then stat -c permissions=%a $CAFILE; fi
+# Kept for backwards compatibility, use "kb-summarizer helper cafile-permissions".
+
+exec kb-summarizer helper cafile-permissions --root /node
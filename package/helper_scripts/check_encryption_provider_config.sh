@@ -1,25 +1,14 @@
 #!/usr/bin/env bash
 
-# This script is used to check the encrption provider config is set to aesbc
+# This script is used to check the encryption provider config uses one of
+# the given providers for secrets
+#
+# inputs:
+#   $@ = approved providers (ex: aescbc kms secretbox)
 #
 # outputs:
 #   true/false
+#
+# Kept for backwards compatibility, use "kb-summarizer helper encryption-provider-config".
 
-# TODO: Figure out the file location from the kube-apiserver commandline args
-ENCRYPTION_CONFIG_FILE="/node/etc/kubernetes/ssl/encryption.yaml"
-
-if [[ ! -f "${ENCRYPTION_CONFIG_FILE}" ]]; then
-  echo "false"
-  exit
-fi
-
-for provider in "$@"
-do
-  if grep "$provider" "${ENCRYPTION_CONFIG_FILE}"; then
-    echo "true"
-    exit
-  fi
-done
-
-echo "false"
-exit
+exec kb-summarizer helper encryption-provider-config --root /node "$@"
@@ -8,37 +8,7 @@
 #
 # outputs:
 #   true/false
+#
+# Kept for backwards compatibility, use "kb-summarizer helper files-owner".
 
-INPUT_DIR=$1
-
-if [[ "${INPUT_DIR}" == "" ]]; then
-    echo "false"
-    exit
-fi
-
-if [[ $(stat -c %U:%G ${INPUT_DIR}) != "root:root" ]]; then
-    echo "false"
-    exit
-fi
-
-statInfoLines=$(stat -c "%n %U:%G" ${INPUT_DIR}/*)
-while read -r statInfoLine; do
-  f=$(echo ${statInfoLine} | cut -d' ' -f1)
-  p=$(echo ${statInfoLine} | cut -d' ' -f2)
-
-  if [[ $(basename "$f" .pem) == "kube-etcd-"* ]]; then
-    if [[ "$p" != "root:root" && "$p" != "etcd:etcd" ]]; then
-      echo "false"
-      exit
-    fi
-  else
-    if [[ "$p" != "root:root" ]]; then
-      echo "false"
-      exit
-    fi
-  fi
-done <<< "${statInfoLines}"
-
-
-echo "true"
-exit
+exec kb-summarizer helper files-owner "$@"
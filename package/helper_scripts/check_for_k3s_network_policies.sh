@@ -1,19 +1,5 @@
 #!/bin/bash
 
-set -eE
+# Kept for backwards compatibility, use "kb-summarizer helper network-policies --distro k3s".
 
-handle_error() {
-    echo "false"
-}
-
-trap 'handle_error' ERR
-
-for namespace in kube-system kube-public default; do
-  policy_count=$(/var/lib/rancher/k3s/bin/kubectl get networkpolicy -n ${namespace} -o json | jq -r '.items | length')
-  if [ ${policy_count} -eq 0 ]; then
-    echo "false"
-    exit
-  fi
-done
-
-echo "true"
+exec kb-summarizer helper network-policies --distro k3s --namespaces kube-system,kube-public,default "$@"
@@ -1,19 +1,5 @@
 #!/bin/bash
 
-set -eE
+# Kept for backwards compatibility, use "kb-summarizer helper network-policies".
 
-handle_error() {
-    echo "false"
-}
-
-trap 'handle_error' ERR
-
-for namespace in $(kubectl get namespaces --all-namespaces -o json | jq -r '.items[].metadata.name'); do
-  policy_count=$(kubectl get networkpolicy -n ${namespace} -o json | jq '.items | length')
-  if [[ ${policy_count} -eq 0 ]]; then
-    echo "false"
-    exit
-  fi
-done
-
-echo "true"
+exec kb-summarizer helper network-policies "$@"
@@ -1,17 +1,5 @@
 #!/bin/bash
 
-set -eE
+# Kept for backwards compatibility, use "kb-summarizer helper cni-network-policy-support".
 
-handle_error() {
-  echo "false"
-}
-
-trap 'handle_error' ERR
-sleep 10
-COUNT=$(find /etc/cni/net.d -name "*calico*" | wc -l)
-if [ ${COUNT} -eq 0 ]; then
-  echo "false"
-  exit
-fi
-
-echo "true"
+exec kb-summarizer helper cni-network-policy-support "$@"
@@ -0,0 +1,160 @@
+// Package catalog describes the benchmarks of a controls directory, so that
+// the valid benchmark versions can be offered without reading config.yaml.
+package catalog
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/rancher/security-scan/pkg/kb-summarizer/summarizer"
+)
+
+const checkTypeManual = "manual"
+
+// Target is a target of a benchmark and the counts of its checks.
+type Target struct {
+	Name     string `json:"name"`
+	NodeType string `json:"node_type"`
+	Checks   int    `json:"checks"`
+	Scored   int    `json:"scored"`
+	Manual   int    `json:"manual"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Benchmark is a benchmark of the controls directory.
+type Benchmark struct {
+	Name string `json:"name"`
+	// KubernetesVersions are the versions of the version mapping selecting
+	// the benchmark.
+	KubernetesVersions []string  `json:"kubernetes_versions"`
+	Targets            []*Target `json:"targets"`
+	Checks             int       `json:"checks"`
+	Scored             int       `json:"scored"`
+	Manual             int       `json:"manual"`
+	// Loads is false when a controls file of the benchmark does not load.
+	Loads bool `json:"loads"`
+}
+
+// List is the catalog of the benchmarks.
+type List struct {
+	Benchmarks []*Benchmark `json:"benchmarks"`
+}
+
+// Load describes every benchmark of the target mapping of the controls
+// directory.
+func Load(controlsDir string) (*List, error) {
+	names, err := summarizer.Benchmarks(controlsDir)
+	if err != nil {
+		return nil, err
+	}
+	versions, err := summarizer.KubernetesVersions(controlsDir)
+	if err != nil {
+		return nil, err
+	}
+	l := &List{Benchmarks: []*Benchmark{}}
+	for _, name := range names {
+		b, err := describe(controlsDir, name, versions)
+		if err != nil {
+			return nil, err
+		}
+		l.Benchmarks = append(l.Benchmarks, b)
+	}
+	return l, nil
+}
+
+// Show describes a benchmark of the controls directory.
+func Show(controlsDir, name string) (*Benchmark, error) {
+	versions, err := summarizer.KubernetesVersions(controlsDir)
+	if err != nil {
+		return nil, err
+	}
+	return describe(controlsDir, name, versions)
+}
+
+func describe(controlsDir, name string, versions map[string][]string) (*Benchmark, error) {
+	loaded, err := summarizer.LoadBenchmark(controlsDir, name)
+	if err != nil {
+		return nil, err
+	}
+	b := &Benchmark{Name: name, KubernetesVersions: versions[name], Targets: []*Target{}, Loads: true}
+	if b.KubernetesVersions == nil {
+		b.KubernetesVersions = []string{}
+	}
+	for _, t := range loaded.Targets {
+		target := &Target{Name: t.Name, NodeType: nodeTypeName(t.NodeType)}
+		if t.Err != nil {
+			target.Error = t.Err.Error()
+			b.Loads = false
+		}
+		b.Targets = append(b.Targets, target)
+	}
+	for _, c := range loaded.Checks() {
+		for _, target := range b.Targets {
+			if target.Name != c.Target.Name {
+				continue
+			}
+			target.Checks++
+			b.Checks++
+			if c.Scored {
+				target.Scored++
+				b.Scored++
+			}
+			if c.Type == checkTypeManual {
+				target.Manual++
+				b.Manual++
+			}
+		}
+	}
+	return b, nil
+}
+
+func nodeTypeName(nodeType summarizer.NodeType) string {
+	switch nodeType {
+	case summarizer.NodeTypeEtcd:
+		return summarizer.FilePathNodeTypeEtcd
+	case summarizer.NodeTypeMaster:
+		return summarizer.FilePathNodeTypeMaster
+	case summarizer.NodeTypeNode:
+		return summarizer.FilePathNodeTypeNode
+	}
+	return summarizer.FilePathNodeTypeCluster
+}
+
+// Text renders a table of the benchmarks.
+func (l *List) Text() string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tKUBERNETES VERSIONS\tTARGETS\tCHECKS\tSCORED\tMANUAL\tLOADS")
+	for _, bm := range l.Benchmarks {
+		var targets []string
+		for _, t := range bm.Targets {
+			targets = append(targets, t.Name)
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\n", bm.Name, orNone(bm.KubernetesVersions), orNone(targets),
+			bm.Checks, bm.Scored, bm.Manual, bm.Loads)
+	}
+	_ = w.Flush()
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// Text renders the benchmark followed by a table of its targets.
+func (bm *Benchmark) Text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "name: %v\nkubernetes versions: %v\nchecks: %v (scored: %v, manual: %v)\nloads: %v\n\n",
+		bm.Name, orNone(bm.KubernetesVersions), bm.Checks, bm.Scored, bm.Manual, bm.Loads)
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TARGET\tNODE TYPE\tCHECKS\tSCORED\tMANUAL\tERROR")
+	for _, t := range bm.Targets {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\n", t.Name, t.NodeType, t.Checks, t.Scored, t.Manual, t.Error)
+	}
+	_ = w.Flush()
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func orNone(values []string) string {
+	if len(values) == 0 {
+		return "-"
+	}
+	return strings.Join(values, ",")
+}
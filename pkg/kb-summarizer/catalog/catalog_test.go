@@ -0,0 +1,84 @@
+package catalog
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rancher/security-scan/pkg/kb-summarizer/summarizer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testConfig = `
+version_mapping:
+  "1.0": b-1.0
+  "1.1": b-1.0
+target_mapping:
+  b-1.0:
+    - master
+    - policies
+  b-1.1:
+    - master
+`
+
+const testMaster = `
+controls:
+id: 1
+text: master
+type: master
+groups:
+  - id: "1.1"
+    text: group 1.1
+    checks:
+      - id: 1.1.1
+        text: check 1.1.1
+        scored: true
+      - id: 1.1.2
+        text: check 1.1.2
+        type: manual
+`
+
+func writeTestControls(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	require.Nil(t, os.WriteFile(filepath.Join(dir, summarizer.ConfigFilename), []byte(testConfig), 0o600))
+	require.Nil(t, os.MkdirAll(filepath.Join(dir, "b-1.0"), 0o750))
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "b-1.0", "master.yaml"), []byte(testMaster), 0o600))
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "b-1.0", "policies.yaml"), []byte("controls:\ntype: policies\ngroups:\n"), 0o600))
+	return dir
+}
+
+func TestLoad(t *testing.T) {
+	l, err := Load(writeTestControls(t))
+	require.Nil(t, err)
+	require.Len(t, l.Benchmarks, 2)
+
+	b := l.Benchmarks[0]
+	assert.Equal(t, "b-1.0", b.Name)
+	assert.Equal(t, []string{"1.0", "1.1"}, b.KubernetesVersions)
+	assert.True(t, b.Loads)
+	assert.Equal(t, 2, b.Checks)
+	assert.Equal(t, 1, b.Scored)
+	assert.Equal(t, 1, b.Manual)
+	assert.Equal(t, []*Target{
+		{Name: "master", NodeType: "master", Checks: 2, Scored: 1, Manual: 1},
+		{Name: "policies", NodeType: "cluster"},
+	}, b.Targets)
+
+	b = l.Benchmarks[1]
+	assert.Equal(t, "b-1.1", b.Name)
+	assert.Empty(t, b.KubernetesVersions)
+	assert.False(t, b.Loads, "the controls files are missing")
+	assert.NotEmpty(t, b.Targets[0].Error)
+}
+
+func TestShow(t *testing.T) {
+	controlsDir := writeTestControls(t)
+	b, err := Show(controlsDir, "b-1.0")
+	require.Nil(t, err)
+	assert.Contains(t, b.Text(), "checks: 2 (scored: 1, manual: 1)")
+
+	_, err = Show(controlsDir, "b-2.0")
+	assert.NotNil(t, err)
+}
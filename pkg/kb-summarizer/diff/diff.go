@@ -0,0 +1,215 @@
+// Package diff compares the controls of two benchmarks, to review the
+// upgrade from a benchmark to the next one.
+package diff
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rancher/security-scan/pkg/kb-summarizer/summarizer"
+)
+
+const (
+	FieldText        = "text"
+	FieldType        = "type"
+	FieldAudit       = "audit"
+	FieldAuditConfig = "audit_config"
+	FieldTests       = "tests"
+	FieldRemediation = "remediation"
+	FieldScored      = "scored"
+)
+
+// Check is a check of one of the benchmarks.
+type Check struct {
+	ID     string `json:"id"`
+	Target string `json:"target"`
+	Text   string `json:"text"`
+}
+
+// Renumbered is a check whose ID changed.
+type Renumbered struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Text string `json:"text"`
+}
+
+// Moved is a check that moved to another target.
+type Moved struct {
+	ID   string `json:"id"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// FieldChange is a field of a check that changed.
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// Changed is a check whose fields changed, identified by its ID in the new
+// benchmark.
+type Changed struct {
+	ID     string         `json:"id"`
+	Fields []*FieldChange `json:"fields"`
+}
+
+// Result is the difference between two benchmarks.
+type Result struct {
+	From       string        `json:"from"`
+	To         string        `json:"to"`
+	Crosswalk  bool          `json:"crosswalk"`
+	Added      []*Check      `json:"added"`
+	Removed    []*Check      `json:"removed"`
+	Renumbered []*Renumbered `json:"renumbered"`
+	Moved      []*Moved      `json:"moved"`
+	Changed    []*Changed    `json:"changed"`
+}
+
+// Compare pairs the checks of benchmark from with the ones of benchmark to
+// and reports the differences. The crosswalk pairs the renumbered checks when
+// there is one. Otherwise a check is paired with the only check of the other
+// benchmark with the same text, or else with the check with the same ID.
+func Compare(from, to *summarizer.Benchmark, crosswalk *summarizer.Crosswalk) *Result {
+	r := &Result{
+		From:       from.Name,
+		To:         to.Name,
+		Crosswalk:  crosswalk != nil,
+		Added:      []*Check{},
+		Removed:    []*Check{},
+		Renumbered: []*Renumbered{},
+		Moved:      []*Moved{},
+		Changed:    []*Changed{},
+	}
+	oldChecks, newChecks := index(from), index(to)
+	newByText := map[string][]string{}
+	for _, id := range newChecks.ids {
+		t := summarizer.NormalizeText(newChecks.checks[id].Text)
+		newByText[t] = append(newByText[t], id)
+	}
+
+	// pair the checks with the same text first, so that the check that took
+	// the ID of a removed one isn't paired with it
+	pairs := map[string]string{}
+	paired := map[string]bool{}
+	for _, id := range oldChecks.ids {
+		newID := ""
+		if crosswalk != nil {
+			if translated, ok := crosswalk.Translate(id); ok {
+				newID = translated
+			}
+		} else if ids := newByText[summarizer.NormalizeText(oldChecks.checks[id].Text)]; len(ids) == 1 {
+			newID = ids[0]
+		}
+		if _, ok := newChecks.checks[newID]; ok && !paired[newID] {
+			pairs[id] = newID
+			paired[newID] = true
+		}
+	}
+	if crosswalk == nil {
+		for _, id := range oldChecks.ids {
+			if _, ok := pairs[id]; !ok && !paired[id] && newChecks.checks[id] != nil {
+				pairs[id] = id
+				paired[id] = true
+			}
+		}
+	}
+
+	for _, id := range oldChecks.ids {
+		old := oldChecks.checks[id]
+		newID, ok := pairs[id]
+		if !ok {
+			r.Removed = append(r.Removed, &Check{ID: id, Target: old.Target.Name, Text: old.Text})
+			continue
+		}
+		check := newChecks.checks[newID]
+		if newID != id {
+			r.Renumbered = append(r.Renumbered, &Renumbered{From: id, To: newID, Text: check.Text})
+		}
+		if old.Target.Name != check.Target.Name {
+			r.Moved = append(r.Moved, &Moved{ID: newID, From: old.Target.Name, To: check.Target.Name})
+		}
+		if fields := compareFields(old, check); len(fields) > 0 {
+			r.Changed = append(r.Changed, &Changed{ID: newID, Fields: fields})
+		}
+	}
+	for _, id := range newChecks.ids {
+		if !paired[id] {
+			c := newChecks.checks[id]
+			r.Added = append(r.Added, &Check{ID: id, Target: c.Target.Name, Text: c.Text})
+		}
+	}
+	return r
+}
+
+type checks struct {
+	ids    []string
+	checks map[string]*summarizer.BenchmarkCheck
+}
+
+// index returns the checks of the benchmark by ID, keeping the first target
+// of the checks of several targets.
+func index(b *summarizer.Benchmark) *checks {
+	c := &checks{checks: map[string]*summarizer.BenchmarkCheck{}}
+	for _, check := range b.Checks() {
+		if _, ok := c.checks[check.ID]; ok {
+			continue
+		}
+		c.ids = append(c.ids, check.ID)
+		c.checks[check.ID] = check
+	}
+	return c
+}
+
+func compareFields(old, check *summarizer.BenchmarkCheck) []*FieldChange {
+	var fields []*FieldChange
+	add := func(field, o, n string) {
+		if o != n {
+			fields = append(fields, &FieldChange{Field: field, Old: o, New: n})
+		}
+	}
+	add(FieldText, old.Text, check.Text)
+	add(FieldType, old.Type, check.Type)
+	add(FieldAudit, strings.TrimSpace(old.Audit), strings.TrimSpace(check.Audit))
+	add(FieldAuditConfig, strings.TrimSpace(old.AuditConfig), strings.TrimSpace(check.AuditConfig))
+	add(FieldTests, old.TestsYAML(), check.TestsYAML())
+	add(FieldRemediation, strings.TrimSpace(old.Remediation), strings.TrimSpace(check.Remediation))
+	add(FieldScored, fmt.Sprint(old.Scored), fmt.Sprint(check.Scored))
+	return fields
+}
+
+// Text renders one line per difference, followed by the old and new values
+// of the changed fields.
+func (r *Result) Text() string {
+	var lines []string
+	for _, c := range r.Added {
+		lines = append(lines, fmt.Sprintf("added %v [%v] %q", c.ID, c.Target, c.Text))
+	}
+	for _, c := range r.Removed {
+		lines = append(lines, fmt.Sprintf("removed %v [%v] %q", c.ID, c.Target, c.Text))
+	}
+	for _, c := range r.Renumbered {
+		lines = append(lines, fmt.Sprintf("renumbered %v -> %v %q", c.From, c.To, c.Text))
+	}
+	for _, c := range r.Moved {
+		lines = append(lines, fmt.Sprintf("moved %v %v -> %v", c.ID, c.From, c.To))
+	}
+	for _, c := range r.Changed {
+		for _, f := range c.Fields {
+			lines = append(lines, fmt.Sprintf("changed %v %v", c.ID, f.Field))
+			lines = append(lines, indent("- ", f.Old), indent("+ ", f.New))
+		}
+	}
+	if len(lines) == 0 {
+		return fmt.Sprintf("no differences between %v and %v", r.From, r.To)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func indent(prefix, value string) string {
+	lines := strings.Split(value, "\n")
+	for i := range lines {
+		lines[i] = "    " + prefix + lines[i]
+	}
+	return strings.Join(lines, "\n")
+}
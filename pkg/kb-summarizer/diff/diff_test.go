@@ -0,0 +1,106 @@
+package diff
+
+import (
+	"testing"
+
+	kb "github.com/aquasecurity/kube-bench/check"
+	"github.com/rancher/security-scan/pkg/kb-summarizer/summarizer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func benchmark(t *testing.T, name string, targets map[string]string) *summarizer.Benchmark {
+	t.Helper()
+	b := &summarizer.Benchmark{Name: name}
+	for _, target := range []string{"master", "policies"} {
+		controls := &kb.Controls{}
+		require.Nil(t, yaml.Unmarshal([]byte(targets[target]), controls))
+		b.Targets = append(b.Targets, &summarizer.Target{Name: target, Controls: controls})
+	}
+	return b
+}
+
+const oldMaster = `
+groups:
+  - id: "1.1"
+    checks:
+      - id: 1.1.1
+        text: "Ensure the file permissions (Automated)"
+        audit: stat -c %a /etc/file
+        tests:
+          test_items:
+            - flag: "600"
+        remediation: chmod 600 /etc/file
+        scored: true
+      - id: 1.1.2
+        text: "Ensure the admission of containers (Manual)"
+        scored: false
+`
+
+const oldPolicies = `
+groups:
+  - id: "5.2"
+    checks:
+      - id: 5.2.1
+        text: "Minimize the admission of added capabilities (Manual)"
+        scored: false
+      - id: 5.2.2
+        text: "Minimize the admission of HostPath volumes (Manual)"
+        scored: false
+`
+
+const newMaster = `
+groups:
+  - id: "1.1"
+    checks:
+      - id: 1.1.1
+        text: "Ensure the file permissions (Automated)"
+        audit: stat -c %a /etc/file
+        tests:
+          test_items:
+            - flag: "644"
+        remediation: chmod 644 /etc/file
+        scored: true
+`
+
+const newPolicies = `
+groups:
+  - id: "5.2"
+    checks:
+      - id: 5.2.1
+        text: "Minimize the admission of HostPath volumes (Manual)"
+        scored: true
+      - id: 5.2.2
+        text: "Ensure the admission of containers (Manual)"
+        scored: false
+      - id: 5.2.3
+        text: "Minimize the admission of HostPorts (Manual)"
+        scored: false
+`
+
+func TestCompare(t *testing.T) {
+	from := benchmark(t, "b-1.1", map[string]string{"master": oldMaster, "policies": oldPolicies})
+	to := benchmark(t, "b-1.2", map[string]string{"master": newMaster, "policies": newPolicies})
+
+	r := Compare(from, to, nil)
+	assert.False(t, r.Crosswalk)
+	assert.Equal(t, []*Check{{ID: "5.2.3", Target: "policies", Text: "Minimize the admission of HostPorts (Manual)"}}, r.Added)
+	// the check took the ID of another one, but is paired by its text
+	assert.Equal(t, []*Check{{ID: "5.2.1", Target: "policies", Text: "Minimize the admission of added capabilities (Manual)"}}, r.Removed)
+	assert.Equal(t, []*Renumbered{
+		{From: "1.1.2", To: "5.2.2", Text: "Ensure the admission of containers (Manual)"},
+		{From: "5.2.2", To: "5.2.1", Text: "Minimize the admission of HostPath volumes (Manual)"},
+	}, r.Renumbered)
+	assert.Equal(t, []*Moved{{ID: "5.2.2", From: "master", To: "policies"}}, r.Moved)
+	require.Len(t, r.Changed, 2)
+	assert.Equal(t, "1.1.1", r.Changed[0].ID)
+	var fields []string
+	for _, f := range r.Changed[0].Fields {
+		fields = append(fields, f.Field)
+	}
+	assert.Equal(t, []string{FieldTests, FieldRemediation}, fields)
+	assert.Equal(t, &Changed{ID: "5.2.1", Fields: []*FieldChange{{Field: FieldScored, Old: "false", New: "true"}}}, r.Changed[1])
+
+	assert.Equal(t, "no differences between b-1.1 and b-1.1", Compare(from, from, nil).Text())
+}
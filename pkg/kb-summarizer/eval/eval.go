@@ -0,0 +1,140 @@
+// Package eval evaluates the tests of a check against captured audit outputs
+// with the check logic of kube-bench, without running a scan.
+package eval
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	kb "github.com/aquasecurity/kube-bench/check"
+	"github.com/rancher/security-scan/pkg/kb-summarizer/summarizer"
+)
+
+// shell is the shell kube-bench runs the audit commands with.
+const shell = "/bin/sh"
+
+// Outputs are the captured outputs of the audit commands of a check.
+type Outputs struct {
+	Audit       string `json:"audit"`
+	AuditConfig string `json:"audit_config,omitempty"`
+	AuditEnv    string `json:"audit_env,omitempty"`
+}
+
+// Item is the result of a test item of the check evaluated on its own.
+type Item struct {
+	State       kb.State `json:"state"`
+	Expected    string   `json:"expected"`
+	ActualValue string   `json:"actual_value"`
+}
+
+// Result is the state kube-bench gives the check for the outputs, and why.
+type Result struct {
+	Benchmark      string   `json:"benchmark"`
+	ID             string   `json:"id"`
+	Description    string   `json:"description"`
+	State          kb.State `json:"state"`
+	Reason         string   `json:"reason,omitempty"`
+	ExpectedResult string   `json:"expected_result"`
+	ActualValue    string   `json:"actual_value"`
+	BinOp          string   `json:"bin_op,omitempty"`
+	Items          []*Item  `json:"items"`
+}
+
+// Evaluate runs the tests of the check of the benchmark against the outputs.
+// The audit commands are replaced with commands printing the outputs, which
+// kube-bench runs through /bin/sh.
+func Evaluate(benchmark string, c *summarizer.BenchmarkCheck, outputs *Outputs) (*Result, error) {
+	if err := checkShell(); err != nil {
+		return nil, err
+	}
+	check := run(c.Check, outputs)
+	r := &Result{
+		Benchmark:      benchmark,
+		ID:             check.ID,
+		Description:    check.Text,
+		State:          check.State,
+		Reason:         check.Reason,
+		ExpectedResult: check.ExpectedResult,
+		ActualValue:    check.ActualValue,
+		Items:          []*Item{},
+	}
+	if c.Tests == nil {
+		return r, nil
+	}
+	r.BinOp = string(c.Tests.BinOp)
+	for i := range c.Tests.TestItems {
+		// the copy keeps the unexported type of the tests
+		tests := *c.Tests
+		tests.TestItems = tests.TestItems[i : i+1]
+		tests.BinOp = ""
+		control := *c.Check
+		control.Tests = &tests
+		// the type of a manual check skips its tests, and an unscored one
+		// warns instead of failing
+		control.Type = ""
+		control.Scored = true
+		item := run(&control, outputs)
+		r.Items = append(r.Items, &Item{State: item.State, Expected: item.ExpectedResult, ActualValue: item.ActualValue})
+	}
+	return r, nil
+}
+
+func checkShell() error {
+	if _, err := os.Stat(shell); err != nil {
+		return fmt.Errorf("evaluating a check requires %v, kube-bench runs the audit commands with it: %w", shell, err)
+	}
+	return nil
+}
+
+// run returns a copy of the check run by kube-bench with audit commands
+// printing the outputs.
+func run(c *kb.Check, outputs *Outputs) *kb.Check {
+	check := *c
+	check.Audit = printCommand(outputs.Audit)
+	check.AuditConfig = printCommand(outputs.AuditConfig)
+	check.AuditEnv = printCommand(outputs.AuditEnv)
+	check.State = ""
+	check.Reason = ""
+	check.ActualValue = ""
+	check.ExpectedResult = ""
+	kb.NewRunner().Run(&check)
+	check.Audit, check.AuditConfig, check.AuditEnv = c.Audit, c.AuditConfig, c.AuditEnv
+	return &check
+}
+
+// printCommand returns a command printing the output as it is, nothing for
+// an empty output so that kube-bench runs no command.
+func printCommand(output string) string {
+	if output == "" {
+		return ""
+	}
+	return "printf '%s' '" + strings.ReplaceAll(output, "'", `'\''`) + "'"
+}
+
+// Text renders the state and the reason of the check, followed by the result
+// of each test item.
+func (r *Result) Text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "check %v: %v\n", r.ID, r.Description)
+	fmt.Fprintf(&b, "benchmark: %v\n", r.Benchmark)
+	fmt.Fprintf(&b, "state: %v\n", r.State)
+	if r.Reason != "" {
+		fmt.Fprintf(&b, "reason: %v\n", r.Reason)
+	}
+	if r.ExpectedResult != "" {
+		fmt.Fprintf(&b, "expected: %v\n", r.ExpectedResult)
+	}
+	fmt.Fprintf(&b, "actual value: %q\n", r.ActualValue)
+	if len(r.Items) > 0 {
+		binOp := r.BinOp
+		if binOp == "" {
+			binOp = "and"
+		}
+		fmt.Fprintf(&b, "test items (%v):\n", binOp)
+		for _, item := range r.Items {
+			fmt.Fprintf(&b, "    %v %v, actual value %q\n", item.State, item.Expected, item.ActualValue)
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,76 @@
+package eval
+
+import (
+	"os"
+	"testing"
+
+	kb "github.com/aquasecurity/kube-bench/check"
+	"github.com/rancher/security-scan/pkg/kb-summarizer/summarizer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+const testCheck = `
+id: 1.1.1
+text: "Ensure the file permissions and ownership (Automated)"
+audit: stat -c permissions=%a /etc/file
+tests:
+  bin_op: or
+  test_items:
+    - flag: "permissions"
+      compare:
+        op: bitmask
+        value: "600"
+    - flag: "root:root"
+scored: true
+`
+
+// requireShell skips the test where kube-bench cannot run audit commands.
+func requireShell(t *testing.T) {
+	t.Helper()
+	if _, err := os.Stat(shell); err != nil {
+		t.Skipf("kube-bench runs the audit commands with %v", shell)
+	}
+}
+
+func testBenchmarkCheck(t *testing.T) *summarizer.BenchmarkCheck {
+	t.Helper()
+	requireShell(t)
+	c := &kb.Check{}
+	require.Nil(t, yaml.Unmarshal([]byte(testCheck), c))
+	return &summarizer.BenchmarkCheck{Check: c}
+}
+
+func TestEvaluate(t *testing.T) {
+	c := testBenchmarkCheck(t)
+	tests := []struct {
+		name   string
+		output string
+		state  kb.State
+		items  []kb.State
+	}{
+		{name: "restrictive", output: "permissions=600\n", state: kb.PASS, items: []kb.State{kb.PASS, kb.FAIL}},
+		{name: "permissive", output: "permissions=644\n", state: kb.FAIL, items: []kb.State{kb.FAIL, kb.FAIL}},
+		{name: "owner", output: "permissions=644 root:root\n", state: kb.PASS, items: []kb.State{kb.FAIL, kb.PASS}},
+		{name: "quotes", output: "it's 'quoted'", state: kb.FAIL, items: []kb.State{kb.FAIL, kb.FAIL}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := Evaluate("b-1.0", c, &Outputs{Audit: tt.output})
+			require.Nil(t, err)
+			assert.Equal(t, tt.state, r.State)
+			assert.Equal(t, "or", r.BinOp)
+			var items []kb.State
+			for _, item := range r.Items {
+				items = append(items, item.State)
+			}
+			assert.Equal(t, tt.items, items)
+		})
+	}
+
+	r, err := Evaluate("b-1.0", c, &Outputs{Audit: "it's 'quoted'"})
+	require.Nil(t, err)
+	assert.Equal(t, "it's 'quoted'", r.ActualValue, "the output is printed as it is")
+	assert.Equal(t, "stat -c permissions=%a /etc/file", c.Audit, "the check is left unchanged")
+}
@@ -0,0 +1,143 @@
+package eval
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	kb "github.com/aquasecurity/kube-bench/check"
+	"github.com/rancher/security-scan/pkg/kb-summarizer/summarizer"
+)
+
+// FixturesFilename is the fixtures file of a benchmark. It is a JSON file, as
+// every YAML file of a benchmark directory is a controls file. It maps the
+// check IDs to samples of the outputs of their audit commands and the state
+// kube-bench should give the check for them:
+//
+//	{"1.2.1": [
+//	  {"name": "disabled", "audit": "kube-apiserver --anonymous-auth=false", "state": "PASS"},
+//	  {"name": "enabled", "audit": "kube-apiserver --anonymous-auth=true", "state": "FAIL"}
+//	]}
+const FixturesFilename = "fixtures.json"
+
+// Fixture is a sample of the audit outputs of a check and its expected state.
+type Fixture struct {
+	Name string `json:"name"`
+	Outputs
+	State kb.State `json:"state"`
+}
+
+// Fixtures map the check IDs of a benchmark to their fixtures.
+type Fixtures map[string][]*Fixture
+
+// LoadFixtures reads the fixtures file of the benchmark. A benchmark without
+// a fixtures file has no fixtures.
+func LoadFixtures(controlsDir, benchmark string) (Fixtures, error) {
+	fixtures := Fixtures{}
+	fixturesFile := filepath.Clean(filepath.Join(controlsDir, benchmark, FixturesFilename))
+	data, err := os.ReadFile(fixturesFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return fixtures, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading file %v: %v", fixturesFile, err)
+	}
+	if err := json.Unmarshal(data, &fixtures); err != nil {
+		return nil, fmt.Errorf("error unmarshalling fixtures file %v: %w", fixturesFile, err)
+	}
+	return fixtures, nil
+}
+
+// FixtureResult is the state kube-bench gave a check for a fixture.
+type FixtureResult struct {
+	Benchmark string   `json:"benchmark"`
+	Check     string   `json:"check"`
+	Name      string   `json:"name"`
+	Expected  kb.State `json:"expected"`
+	State     kb.State `json:"state,omitempty"`
+	Reason    string   `json:"reason,omitempty"`
+	// Error is set when the fixture could not be evaluated, e.g. when the
+	// check is not part of the benchmark.
+	Error string `json:"error,omitempty"`
+}
+
+// Passed reports whether the check got the expected state.
+func (r *FixtureResult) Passed() bool {
+	return r.Error == "" && r.State == r.Expected
+}
+
+// FixturesReport is the result of the fixtures of the benchmarks.
+type FixturesReport struct {
+	Passed  int              `json:"passed"`
+	Failed  int              `json:"failed"`
+	Results []*FixtureResult `json:"results"`
+}
+
+// RunFixtures evaluates the fixtures of the benchmarks of the controls
+// directory, in the order of the benchmarks and of the check IDs.
+func RunFixtures(controlsDir string, benchmarks []string) (*FixturesReport, error) {
+	report := &FixturesReport{Results: []*FixtureResult{}}
+	for _, benchmark := range benchmarks {
+		fixtures, err := LoadFixtures(controlsDir, benchmark)
+		if err != nil {
+			return nil, err
+		}
+		if len(fixtures) == 0 {
+			continue
+		}
+		b, err := summarizer.LoadBenchmark(controlsDir, benchmark)
+		if err != nil {
+			return nil, err
+		}
+		ids := make([]string, 0, len(fixtures))
+		for id := range fixtures {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			check := b.Check(id)
+			for _, f := range fixtures[id] {
+				r := &FixtureResult{Benchmark: benchmark, Check: id, Name: f.Name, Expected: f.State}
+				if check == nil {
+					r.Error = fmt.Sprintf("check %v is not part of benchmark %v", id, benchmark)
+				} else if result, err := Evaluate(benchmark, check, &f.Outputs); err != nil {
+					r.Error = err.Error()
+				} else {
+					r.State = result.State
+					r.Reason = result.Reason
+				}
+				if r.Passed() {
+					report.Passed++
+				} else {
+					report.Failed++
+				}
+				report.Results = append(report.Results, r)
+			}
+		}
+	}
+	return report, nil
+}
+
+// Text renders one line per fixture, followed by the totals.
+func (r *FixturesReport) Text() string {
+	var lines []string
+	for _, f := range r.Results {
+		line := fmt.Sprintf("ok   %v %v %q", f.Benchmark, f.Check, f.Name)
+		switch {
+		case f.Error != "":
+			line = fmt.Sprintf("FAIL %v %v %q: %v", f.Benchmark, f.Check, f.Name, f.Error)
+		case !f.Passed():
+			line = fmt.Sprintf("FAIL %v %v %q: expected %v, got %v", f.Benchmark, f.Check, f.Name, f.Expected, f.State)
+			if f.Reason != "" {
+				line += " (" + f.Reason + ")"
+			}
+		}
+		lines = append(lines, line)
+	}
+	lines = append(lines, fmt.Sprintf("%v passed, %v failed", r.Passed, r.Failed))
+	return strings.Join(lines, "\n")
+}
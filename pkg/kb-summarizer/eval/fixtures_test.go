@@ -0,0 +1,72 @@
+package eval
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rancher/security-scan/pkg/kb-summarizer/summarizer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// cfgDir is the controls directory of the repository.
+const cfgDir = "../../../package/cfg"
+
+func TestRunFixtures(t *testing.T) {
+	requireShell(t)
+	controlsDir := t.TempDir()
+	require.Nil(t, os.WriteFile(filepath.Join(controlsDir, summarizer.ConfigFilename), []byte("target_mapping:\n  b-1.0:\n    - master\n"), 0o600))
+	require.Nil(t, os.MkdirAll(filepath.Join(controlsDir, "b-1.0"), 0o750))
+	require.Nil(t, os.WriteFile(filepath.Join(controlsDir, "b-1.0", "master.yaml"), []byte(`
+controls:
+type: master
+groups:
+  - id: "1.1"
+    checks:
+      - id: 1.1.1
+        text: "Ensure the file permissions (Automated)"
+        audit: stat -c permissions=%a /etc/file
+        tests:
+          test_items:
+            - flag: "permissions"
+              compare:
+                op: bitmask
+                value: "600"
+        scored: true
+`), 0o600))
+	require.Nil(t, os.WriteFile(filepath.Join(controlsDir, "b-1.0", FixturesFilename), []byte(`{
+		"1.1.1": [
+			{"name": "restrictive", "audit": "permissions=600\n", "state": "PASS"},
+			{"name": "wrong expectation", "audit": "permissions=644\n", "state": "PASS"}
+		],
+		"9.9.9": [{"name": "unknown check", "audit": "", "state": "PASS"}]
+	}`), 0o600))
+
+	r, err := RunFixtures(controlsDir, []string{"b-1.0"})
+	require.Nil(t, err)
+	assert.Equal(t, 1, r.Passed)
+	assert.Equal(t, 2, r.Failed)
+	require.Len(t, r.Results, 3)
+	assert.True(t, r.Results[0].Passed())
+	assert.Equal(t, "FAIL", string(r.Results[1].State))
+	assert.NotEmpty(t, r.Results[2].Error)
+	assert.Contains(t, r.Text(), `FAIL b-1.0 1.1.1 "wrong expectation": expected PASS, got FAIL`)
+
+	_, err = RunFixtures(controlsDir, []string{"b-2.0"})
+	assert.Nil(t, err, "a benchmark without fixtures has nothing to run")
+}
+
+// TestFixtures runs the fixtures of the benchmarks of the repository, so that
+// the changes to the controls are tested.
+func TestFixtures(t *testing.T) {
+	requireShell(t)
+	benchmarks, err := summarizer.Benchmarks(cfgDir)
+	require.Nil(t, err)
+	r, err := RunFixtures(cfgDir, benchmarks)
+	require.Nil(t, err)
+	assert.NotEmpty(t, r.Results)
+	for _, f := range r.Results {
+		assert.True(t, f.Passed(), "%v %v %q: expected %v, got %v %v", f.Benchmark, f.Check, f.Name, f.Expected, f.State, f.Error)
+	}
+}
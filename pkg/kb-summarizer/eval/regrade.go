@@ -0,0 +1,72 @@
+package eval
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	kb "github.com/aquasecurity/kube-bench/check"
+)
+
+// variable is a variable of an audit command, which kube-bench replaces with
+// the value of the node before running it.
+var variable = regexp.MustCompile(`\\\$[A-Za-z_][A-Za-z0-9_]*`)
+
+// Regrader grades the results of kube-bench again against the current
+// controls, from the output of the audit command kept as the actual value of
+// the results.
+type Regrader struct{}
+
+// Regrade runs the tests of the control against the actual value of the
+// result. The result can't be regraded when the audit commands of the control
+// changed, or when its audit output was not kept: kube-bench keeps the output
+// of one of the audit commands only, and none when the audit failed or did
+// not run. The regraded check keeps the audit commands kube-bench ran for the
+// result, with their variables replaced.
+func (Regrader) Regrade(control, result *kb.Check) (*kb.Check, error) {
+	if err := checkShell(); err != nil {
+		return nil, err
+	}
+	for _, audit := range []struct{ name, control, result string }{
+		{"audit", control.Audit, result.Audit},
+		{"audit_config", control.AuditConfig, result.AuditConfig},
+		{"audit_env", control.AuditEnv, result.AuditEnv},
+	} {
+		if !auditMatches(audit.control, audit.result) {
+			return nil, fmt.Errorf("the %v command changed", audit.name)
+		}
+	}
+	outputs := &Outputs{}
+	if runsAudit(control) {
+		if strings.TrimSpace(control.AuditConfig) != "" || strings.TrimSpace(control.AuditEnv) != "" {
+			return nil, fmt.Errorf("the actual value may be the output of the audit_config or audit_env command")
+		}
+		if result.Reason != "" {
+			return nil, fmt.Errorf("the audit output was not kept: %v", result.Reason)
+		}
+		outputs.Audit = result.ActualValue
+	}
+	regraded := run(control, outputs)
+	regraded.Audit, regraded.AuditConfig, regraded.AuditEnv = result.Audit, result.AuditConfig, result.AuditEnv
+	return regraded, nil
+}
+
+// auditMatches reports whether the audit command run by kube-bench is the one
+// of the control, once its variables are replaced.
+func auditMatches(control, result string) bool {
+	control, result = strings.TrimSpace(control), strings.TrimSpace(result)
+	if control == result {
+		return true
+	}
+	pattern := variable.ReplaceAllString(regexp.QuoteMeta(control), `\S*`)
+	return regexp.MustCompile("^" + pattern + "$").MatchString(result)
+}
+
+// runsAudit reports whether kube-bench runs the audit commands of the check,
+// rather than giving it a state from its type or its lack of tests.
+func runsAudit(c *kb.Check) bool {
+	if c.Type == kb.SKIP || c.Type == kb.MANUAL {
+		return false
+	}
+	return c.Tests != nil && len(c.Tests.TestItems) > 0
+}
@@ -0,0 +1,58 @@
+package eval
+
+import (
+	"testing"
+
+	kb "github.com/aquasecurity/kube-bench/check"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+const testControl = `
+id: 1.2.1
+text: "Ensure that the --anonymous-auth argument is set to false (Automated)"
+audit: "/bin/ps -fC $apiserverbin"
+tests:
+  test_items:
+    - flag: "--anonymous-auth"
+      compare:
+        op: eq
+        value: false
+scored: true
+`
+
+func TestRegrader(t *testing.T) {
+	requireShell(t)
+	control := &kb.Check{}
+	require.Nil(t, yaml.Unmarshal([]byte(testControl), control))
+	result := func(audit, actualValue, reason string) *kb.Check {
+		return &kb.Check{ID: "1.2.1", Audit: audit, ActualValue: actualValue, Reason: reason, State: kb.PASS}
+	}
+
+	regraded, err := Regrader{}.Regrade(control, result("/bin/ps -fC kube-apiserver", "kube-apiserver --anonymous-auth=true", ""))
+	require.Nil(t, err)
+	assert.Equal(t, kb.FAIL, regraded.State, "the stored output fails the current tests")
+	assert.Equal(t, "kube-apiserver --anonymous-auth=true", regraded.ActualValue)
+	assert.Equal(t, "/bin/ps -fC kube-apiserver", regraded.Audit, "the regraded check keeps the command which ran")
+
+	_, err = Regrader{}.Regrade(control, result("/bin/ps -ef | grep kube-apiserver", "kube-apiserver --anonymous-auth=true", ""))
+	assert.ErrorContains(t, err, "audit command changed")
+
+	_, err = Regrader{}.Regrade(control, result("/bin/ps -fC kube-apiserver", "", "failed to run"))
+	assert.ErrorContains(t, err, "not kept")
+
+	withConfig := *control
+	withConfig.AuditConfig = "cat $apiserverconf"
+	stored := result("/bin/ps -fC kube-apiserver", "kube-apiserver", "")
+	stored.AuditConfig = "cat /etc/kube-apiserver.yaml"
+	_, err = Regrader{}.Regrade(&withConfig, stored)
+	assert.ErrorContains(t, err, "audit_config")
+
+	manual := *control
+	manual.Type = kb.MANUAL
+	regraded, err = Regrader{}.Regrade(&manual, result("/bin/ps -fC kube-apiserver", "", "Test marked as a manual test"))
+	require.Nil(t, err)
+	assert.Equal(t, kb.WARN, regraded.State)
+	assert.Equal(t, "/bin/ps -fC kube-apiserver", regraded.Audit)
+}
@@ -0,0 +1,245 @@
+// Package explain describes a check of a benchmark, from its control
+// definition and, given a report, from its results on each node.
+package explain
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	kb "github.com/aquasecurity/kube-bench/check"
+	"github.com/rancher/security-scan/pkg/kb-summarizer/report"
+	"github.com/rancher/security-scan/pkg/kb-summarizer/summarizer"
+)
+
+// NoResult is the state of the nodes of the check's node types which did not
+// report it.
+const NoResult report.State = "noResult"
+
+// Target is a target of the benchmark the check belongs to.
+type Target struct {
+	Name     string `json:"name"`
+	NodeType string `json:"node_type"`
+}
+
+// Node is the result of the check on a node.
+type Node struct {
+	Name        string       `json:"name"`
+	State       report.State `json:"state"`
+	ActualValue string       `json:"actual_value"`
+}
+
+// Result is the result of the check in a report.
+type Result struct {
+	State            report.State          `json:"state"`
+	Provenance       report.Provenance     `json:"provenance,omitempty"`
+	ProvenanceReason string                `json:"provenance_reason,omitempty"`
+	Exception        *summarizer.Exception `json:"exception,omitempty"`
+	Nodes            []*Node               `json:"nodes"`
+	// Hosts are the masters that evaluated a cluster check, when they
+	// disagree. The cluster node has the least compliant of their states.
+	Hosts []*Node `json:"hosts,omitempty"`
+}
+
+// Explanation is the control definition of a check, and its result when a
+// report is given.
+type Explanation struct {
+	Benchmark   string    `json:"benchmark"`
+	ID          string    `json:"id"`
+	Description string    `json:"description"`
+	Group       string    `json:"group"`
+	Targets     []*Target `json:"targets"`
+	Type        string    `json:"type,omitempty"`
+	Scored      bool      `json:"scored"`
+	Audit       string    `json:"audit"`
+	AuditConfig string    `json:"audit_config,omitempty"`
+	Tests       string    `json:"tests,omitempty"`
+	Remediation string    `json:"remediation"`
+	Result      *Result   `json:"result,omitempty"`
+}
+
+// Explain describes the check of the benchmark, along with its result in the
+// report when it is not nil.
+func Explain(b *summarizer.Benchmark, id string, r *report.Report) (*Explanation, error) {
+	var checks []*summarizer.BenchmarkCheck
+	for _, c := range b.Checks() {
+		if c.ID == id {
+			checks = append(checks, c)
+		}
+	}
+	if len(checks) == 0 {
+		return nil, fmt.Errorf("check %v is not part of benchmark %v", id, b.Name)
+	}
+	c := checks[0]
+	e := &Explanation{
+		Benchmark:   b.Name,
+		ID:          c.ID,
+		Description: c.Text,
+		Group:       c.Group.ID + " " + c.Group.Text,
+		Type:        c.Type,
+		Scored:      c.Scored,
+		Audit:       strings.TrimSpace(c.Audit),
+		AuditConfig: strings.TrimSpace(c.AuditConfig),
+		Tests:       c.TestsYAML(),
+		Remediation: strings.TrimSpace(c.Remediation),
+	}
+	for _, c := range checks {
+		e.Targets = append(e.Targets, &Target{Name: c.Target.Name, NodeType: string(nodeTypeName(c.Target.NodeType))})
+	}
+	if r == nil {
+		return e, nil
+	}
+	result, err := explainResult(r, id)
+	if err != nil {
+		return nil, err
+	}
+	e.Result = result
+	return e, nil
+}
+
+func explainResult(r *report.Report, id string) (*Result, error) {
+	check := r.Check(id)
+	if check == nil {
+		return nil, fmt.Errorf("check %v is not part of the report of benchmark %v", id, r.Version)
+	}
+	actualValues, err := r.ActualValues()
+	if err != nil {
+		return nil, fmt.Errorf("error decoding the actual values of the report: %w", err)
+	}
+	result := &Result{
+		State:            check.State,
+		Provenance:       check.Provenance,
+		ProvenanceReason: check.ProvenanceReason,
+		Exception:        check.Exception,
+		Nodes:            []*Node{},
+	}
+	values := check.ActualValueNodeMap
+	var states map[string]kb.State
+	if av := actualValues[id]; av != nil {
+		values = av.ActualValueNodeMap
+		states = av.StateNodeMap
+	}
+	nodes := map[string]bool{}
+	for n := range values {
+		nodes[n] = true
+	}
+	for n := range states {
+		nodes[n] = true
+	}
+	for _, t := range check.NodeType {
+		for _, n := range r.Nodes[t] {
+			nodes[n] = true
+		}
+	}
+	names := make([]string, 0, len(nodes))
+	for n := range nodes {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		node := &Node{Name: n, ActualValue: values[n]}
+		switch state, ok := states[n]; {
+		case ok:
+			node.State = report.NodeState(state)
+		case !hasKey(values, n):
+			node.State = NoResult
+		case check.State != report.Mixed:
+			// the reports written before the state of each node was kept
+			node.State = check.State
+		}
+		result.Nodes = append(result.Nodes, node)
+	}
+	hosts := make([]string, 0, len(check.HostStates))
+	for h := range check.HostStates {
+		hosts = append(hosts, h)
+	}
+	sort.Strings(hosts)
+	for _, h := range hosts {
+		result.Hosts = append(result.Hosts, &Node{Name: h, State: check.HostStates[h]})
+	}
+	return result, nil
+}
+
+func hasKey(m map[string]string, key string) bool {
+	_, ok := m[key]
+	return ok
+}
+
+func nodeTypeName(nodeType summarizer.NodeType) report.NodeType {
+	switch nodeType {
+	case summarizer.NodeTypeEtcd:
+		return report.NodeTypeEtcd
+	case summarizer.NodeTypeMaster:
+		return report.NodeTypeMaster
+	case summarizer.NodeTypeNode:
+		return report.NodeTypeNode
+	}
+	return report.NodeTypeCluster
+}
+
+// Text renders the control definition, followed by the result of each node.
+func (e *Explanation) Text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "check %v: %v\n", e.ID, e.Description)
+	fmt.Fprintf(&b, "benchmark: %v\n", e.Benchmark)
+	fmt.Fprintf(&b, "group: %v\n", e.Group)
+	var targets []string
+	for _, t := range e.Targets {
+		targets = append(targets, fmt.Sprintf("%v (%v)", t.Name, t.NodeType))
+	}
+	fmt.Fprintf(&b, "target: %v\n", strings.Join(targets, ", "))
+	if e.Type != "" {
+		fmt.Fprintf(&b, "type: %v\n", e.Type)
+	}
+	fmt.Fprintf(&b, "scored: %v\n", e.Scored)
+	writeBlock(&b, "audit", e.Audit)
+	writeBlock(&b, "audit_config", e.AuditConfig)
+	writeBlock(&b, "tests", e.Tests)
+	writeBlock(&b, "remediation", e.Remediation)
+	if r := e.Result; r != nil {
+		fmt.Fprintf(&b, "\nstate: %v\n", r.State)
+		if r.Provenance != "" {
+			fmt.Fprintf(&b, "overridden by: %v (%v)\n", r.Provenance, r.ProvenanceReason)
+		}
+		if x := r.Exception; x != nil {
+			fmt.Fprintf(&b, "exception: %v", x.ID)
+			for _, field := range [][2]string{{"owner", x.Owner}, {"ticket", x.Ticket}, {"expires", x.Expires}} {
+				if field[1] != "" {
+					fmt.Fprintf(&b, ", %v: %v", field[0], field[1])
+				}
+			}
+			b.WriteString("\n")
+		}
+		for _, n := range r.Nodes {
+			state := n.State
+			if state == "" {
+				state = "unknown"
+			}
+			fmt.Fprintf(&b, "node %v: %v\n", n.Name, state)
+			writeIndented(&b, n.ActualValue)
+		}
+		for _, h := range r.Hosts {
+			fmt.Fprintf(&b, "    evaluated on %v: %v\n", h.Name, h.State)
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// writeBlock writes the label followed by the indented lines of the value,
+// nothing when the value is empty.
+func writeBlock(b *strings.Builder, label, value string) {
+	if value == "" {
+		return
+	}
+	fmt.Fprintf(b, "%v:\n", label)
+	writeIndented(b, value)
+}
+
+func writeIndented(b *strings.Builder, value string) {
+	if value == "" {
+		return
+	}
+	for _, line := range strings.Split(value, "\n") {
+		fmt.Fprintf(b, "    %v\n", line)
+	}
+}
@@ -0,0 +1,131 @@
+package explain
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	kb "github.com/aquasecurity/kube-bench/check"
+	"github.com/rancher/security-scan/pkg/kb-summarizer/report"
+	"github.com/rancher/security-scan/pkg/kb-summarizer/summarizer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+const testNode = `
+groups:
+  - id: "4.1"
+    text: Worker Node Configuration Files
+    checks:
+      - id: 4.1.1
+        text: "Ensure the kubelet service file permissions (Automated)"
+        audit: stat -c %a /etc/kubelet
+        tests:
+          test_items:
+            - flag: "600"
+        remediation: chmod 600 /etc/kubelet
+        scored: true
+`
+
+func testBenchmark(t *testing.T) *summarizer.Benchmark {
+	t.Helper()
+	controls := &kb.Controls{}
+	require.Nil(t, yaml.Unmarshal([]byte(testNode), controls))
+	return &summarizer.Benchmark{Name: "b-1.0", Targets: []*summarizer.Target{
+		{Name: "node", NodeType: summarizer.NodeTypeNode, Controls: controls},
+	}}
+}
+
+// encodeActualValues encodes the actual values the way the summarizer does.
+func encodeActualValues(t *testing.T, groups []*summarizer.ActualValueGroup) string {
+	t.Helper()
+	data, err := json.Marshal(groups)
+	require.Nil(t, err)
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err = w.Write(data)
+	require.Nil(t, err)
+	require.Nil(t, w.Close())
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func TestExplain(t *testing.T) {
+	b := testBenchmark(t)
+	e, err := Explain(b, "4.1.1", nil)
+	require.Nil(t, err)
+	assert.Equal(t, []*Target{{Name: "node", NodeType: "node"}}, e.Targets)
+	assert.Equal(t, "stat -c %a /etc/kubelet", e.Audit)
+	assert.Contains(t, e.Tests, `flag: "600"`)
+	assert.Nil(t, e.Result)
+	assert.Contains(t, e.Text(), "target: node (node)")
+
+	_, err = Explain(b, "4.1.2", nil)
+	assert.NotNil(t, err)
+}
+
+func TestExplain_report(t *testing.T) {
+	r := &report.Report{
+		Version: "b-1.0",
+		Nodes:   map[report.NodeType][]string{report.NodeTypeNode: {"w1", "w2", "w3"}},
+		Results: []*report.Group{{ID: "4.1", Checks: []*report.Check{{
+			ID:               "4.1.1",
+			State:            report.Mixed,
+			NodeType:         []report.NodeType{report.NodeTypeNode},
+			Nodes:            []string{"w2"},
+			Provenance:       report.ProvenanceUserSkip,
+			ProvenanceReason: "legacy hosts",
+			Exception:        &summarizer.Exception{ID: "4.1.1", Owner: "platform", Scope: summarizer.Scope{Nodes: []string{"w1"}}},
+		}}}},
+		ActualValueMapData: encodeActualValues(t, []*summarizer.ActualValueGroup{{ID: "4.1", ActualValueChecks: []*summarizer.ActualValueCheck{{
+			ID:                 "4.1.1",
+			ActualValueNodeMap: map[string]string{"w1": "644", "w2": "644"},
+			StateNodeMap:       map[string]kb.State{"w1": summarizer.SKIP, "w2": kb.FAIL},
+		}}}}),
+	}
+
+	e, err := Explain(testBenchmark(t), "4.1.1", r)
+	require.Nil(t, err)
+	require.NotNil(t, e.Result)
+	assert.Equal(t, report.Mixed, e.Result.State)
+	assert.Equal(t, report.ProvenanceUserSkip, e.Result.Provenance)
+	assert.Equal(t, []*Node{
+		{Name: "w1", State: report.Skip, ActualValue: "644"},
+		{Name: "w2", State: report.Fail, ActualValue: "644"},
+		{Name: "w3", State: NoResult},
+	}, e.Result.Nodes)
+	assert.Contains(t, e.Text(), "exception: 4.1.1, owner: platform")
+
+	r.Results[0].Checks[0].ID = "4.1.2"
+	_, err = Explain(testBenchmark(t), "4.1.1", r)
+	assert.NotNil(t, err, "the check is not part of the report")
+}
+
+func TestExplain_clusterCheck(t *testing.T) {
+	r := &report.Report{
+		Version: "b-1.0",
+		Nodes: map[report.NodeType][]string{
+			report.NodeTypeMaster:  {"m1", "m2"},
+			report.NodeTypeCluster: {summarizer.ClusterNodeName},
+		},
+		Results: []*report.Group{{ID: "4.1", Checks: []*report.Check{{
+			ID:         "4.1.1",
+			State:      report.Fail,
+			NodeType:   []report.NodeType{report.NodeTypeCluster},
+			HostStates: map[string]report.State{"m2": report.Fail, "m1": report.Pass},
+		}}}},
+		ActualValueMapData: encodeActualValues(t, []*summarizer.ActualValueGroup{{ID: "4.1", ActualValueChecks: []*summarizer.ActualValueCheck{{
+			ID:                 "4.1.1",
+			ActualValueNodeMap: map[string]string{summarizer.ClusterNodeName: "644"},
+			StateNodeMap:       map[string]kb.State{summarizer.ClusterNodeName: kb.FAIL},
+		}}}}),
+	}
+
+	e, err := Explain(testBenchmark(t), "4.1.1", r)
+	require.Nil(t, err)
+	assert.Equal(t, []*Node{{Name: summarizer.ClusterNodeName, State: report.Fail, ActualValue: "644"}}, e.Result.Nodes)
+	assert.Equal(t, []*Node{{Name: "m1", State: report.Pass}, {Name: "m2", State: report.Fail}}, e.Result.Hosts)
+	assert.Contains(t, e.Text(), "node cluster: fail\n    644\n    evaluated on m1: pass\n    evaluated on m2: fail")
+}
@@ -0,0 +1,350 @@
+// Package auditpolicy analyzes the audit.k8s.io Policy passed to the
+// kube-apiserver through --audit-policy-file: what is logged at which level,
+// and whether the areas the CIS 3.2.x checks care about are covered.
+package auditpolicy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rancher/security-scan/pkg/kb-summarizer/helpers"
+	"github.com/rancher/security-scan/pkg/kb-summarizer/helpers/components"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	LevelNone            = "None"
+	LevelMetadata        = "Metadata"
+	LevelRequest         = "Request"
+	LevelRequestResponse = "RequestResponse"
+
+	ConfigFlag = "audit-policy-file"
+
+	// ConcernSecretBodies is reported when Secrets can be logged with their
+	// content, i.e. at the Request or RequestResponse level.
+	ConcernSecretBodies = "secret-bodies"
+)
+
+var levels = map[string]int{
+	LevelNone:            0,
+	LevelMetadata:        1,
+	LevelRequest:         2,
+	LevelRequestResponse: 3,
+}
+
+// DefaultPolicyFiles are tried, in order, when the apiserver arguments do not
+// reveal where the policy lives.
+var DefaultPolicyFiles = []string{
+	"/etc/rancher/rke2/audit-policy.yaml",
+	"/var/lib/rancher/k3s/server/audit.yaml",
+}
+
+// Policy mirrors the subset of audit.k8s.io/v1 Policy the analyzer needs.
+type Policy struct {
+	Kind  string       `yaml:"kind"`
+	Rules []PolicyRule `yaml:"rules"`
+}
+
+type PolicyRule struct {
+	Level           string           `yaml:"level"`
+	Users           []string         `yaml:"users"`
+	UserGroups      []string         `yaml:"userGroups"`
+	Verbs           []string         `yaml:"verbs"`
+	Resources       []GroupResources `yaml:"resources"`
+	Namespaces      []string         `yaml:"namespaces"`
+	NonResourceURLs []string         `yaml:"nonResourceURLs"`
+}
+
+type GroupResources struct {
+	Group         string   `yaml:"group"`
+	Resources     []string `yaml:"resources"`
+	ResourceNames []string `yaml:"resourceNames"`
+}
+
+// Concern is an area of the API that must be logged.
+type Concern struct {
+	Name     string
+	Group    string
+	Resource string
+	Verbs    []string
+}
+
+// Concerns are the areas the CIS benchmark asks the policy to cover.
+var Concerns = []Concern{
+	{Name: "secrets", Resource: "secrets", Verbs: []string{"get", "list", "watch", "create", "update", "patch", "delete"}},
+	{Name: "configmaps", Resource: "configmaps", Verbs: []string{"get", "list", "watch", "create", "update", "patch", "delete"}},
+	{Name: "tokenreviews", Group: "authentication.k8s.io", Resource: "tokenreviews", Verbs: []string{"create"}},
+	{Name: "pod-exec", Resource: "pods/exec", Verbs: []string{"get", "create"}},
+}
+
+// Rule summarizes what a rule of the policy logs.
+type Rule struct {
+	Index     int      `json:"index"`
+	Level     string   `json:"level"`
+	Verbs     []string `json:"verbs"`
+	Resources []string `json:"resources,omitempty"`
+	URLs      []string `json:"nonResourceURLs,omitempty"`
+	// Scope lists the users, groups, namespaces or resource names the rule is
+	// restricted to.
+	Scope []string `json:"scope,omitempty"`
+}
+
+// Coverage is the level a concern is logged at, per verb.
+type Coverage struct {
+	Concern string `json:"concern"`
+	// Level is the lowest level across the verbs.
+	Level  string            `json:"level"`
+	Verbs  map[string]string `json:"verbs"`
+	Passed bool              `json:"passed"`
+}
+
+// Finding is a rule logging Secret bodies.
+type Finding struct {
+	Rule  int    `json:"rule"`
+	Verb  string `json:"verb"`
+	Level string `json:"level"`
+}
+
+// Result is the outcome of the analysis.
+type Result struct {
+	Passed       bool        `json:"passed"`
+	PolicyFile   string      `json:"policyFile,omitempty"`
+	Rules        []*Rule     `json:"rules"`
+	Coverage     []*Coverage `json:"coverage"`
+	SecretBodies []*Finding  `json:"secretBodies"`
+}
+
+// Text renders one line per concern in the format of the existing controls,
+// to be tested with use_multiple_values and the is_compliant flag.
+func (r *Result) Text() string {
+	var lines []string
+	for _, c := range r.Coverage {
+		line := fmt.Sprintf("**concern: %s level: %s", c.Concern, c.Level)
+		if !c.Passed {
+			var uncovered []string
+			for _, verb := range concernVerbs(c.Concern) {
+				if c.Verbs[verb] == LevelNone {
+					uncovered = append(uncovered, verb)
+				}
+			}
+			line += " uncovered_verbs: " + strings.Join(uncovered, ",")
+		}
+		lines = append(lines, fmt.Sprintf("%s is_compliant: %v", line, c.Passed))
+	}
+	if len(r.SecretBodies) == 0 {
+		lines = append(lines, fmt.Sprintf("**concern: %s is_compliant: true", ConcernSecretBodies))
+	}
+	for _, f := range r.SecretBodies {
+		lines = append(lines, fmt.Sprintf("**concern: %s rule: %d verb: %s level: %s is_compliant: false",
+			ConcernSecretBodies, f.Rule, f.Verb, f.Level))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Load reads an audit policy file.
+func Load(path string) (*Policy, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	policy := &Policy{}
+	if err := yaml.Unmarshal(data, policy); err != nil {
+		return nil, fmt.Errorf("error parsing audit policy %v: %w", path, err)
+	}
+	if policy.Kind != "Policy" {
+		return nil, fmt.Errorf("%v is not an audit Policy, kind is %q", path, policy.Kind)
+	}
+	for i, rule := range policy.Rules {
+		if _, ok := levels[rule.Level]; !ok {
+			return nil, fmt.Errorf("rule %d of %v has an unknown level %q", i, path, rule.Level)
+		}
+	}
+	return policy, nil
+}
+
+// PolicyFile returns the audit policy of the kube-apiserver, as seen from
+// root. The apiserver arguments are resolved as the component-args helper
+// does.
+func PolicyFile(root, procDir string) (string, error) {
+	r, err := components.Resolve(components.APIServer, components.Options{
+		Root:            root,
+		ProcDir:         procDir,
+		KubeBenchConfig: components.DefaultKubeBenchConfig,
+		DistroConfigs:   components.DefaultDistroConfigs,
+	})
+	if err != nil {
+		return "", err
+	}
+	if path := r.Flags[ConfigFlag]; path != "" {
+		return helpers.HostPath(root, path), nil
+	}
+	for _, path := range DefaultPolicyFiles {
+		path = helpers.HostPath(root, path)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("unable to find the audit policy file")
+}
+
+// Analyze summarizes the rules of policy and resolves the level of every
+// concern. As for the apiserver, the first matching rule decides the level.
+// Rules restricted to some users, groups, namespaces or resource names only
+// decide for those, so they do not count as coverage.
+func Analyze(policy *Policy) *Result {
+	r := &Result{Rules: []*Rule{}, Coverage: []*Coverage{}, SecretBodies: []*Finding{}}
+	for i, rule := range policy.Rules {
+		r.Rules = append(r.Rules, summarize(i, rule))
+	}
+
+	r.Passed = true
+	for _, concern := range Concerns {
+		c := &Coverage{Concern: concern.Name, Level: LevelRequestResponse, Verbs: map[string]string{}, Passed: true}
+		for _, verb := range concern.Verbs {
+			level := LevelNone
+			for _, rule := range policy.Rules {
+				if !rule.scoped() && rule.matches(concern.Group, concern.Resource, verb) {
+					level = rule.Level
+					break
+				}
+			}
+			c.Verbs[verb] = level
+			if levels[level] < levels[c.Level] {
+				c.Level = level
+			}
+			if level == LevelNone {
+				c.Passed = false
+			}
+		}
+		r.Coverage = append(r.Coverage, c)
+		r.Passed = r.Passed && c.Passed
+	}
+
+	// any rule reached before the one deciding for everyone may log bodies
+	for _, verb := range Concerns[0].Verbs {
+		for i, rule := range policy.Rules {
+			if !rule.matches("", "secrets", verb) {
+				continue
+			}
+			if levels[rule.Level] >= levels[LevelRequest] {
+				r.SecretBodies = append(r.SecretBodies, &Finding{Rule: i, Verb: verb, Level: rule.Level})
+			}
+			if !rule.scoped() {
+				break
+			}
+		}
+	}
+	r.Passed = r.Passed && len(r.SecretBodies) == 0
+	return r
+}
+
+func (rule PolicyRule) scoped() bool {
+	if len(rule.Users) > 0 || len(rule.UserGroups) > 0 || len(rule.Namespaces) > 0 {
+		return true
+	}
+	for _, gr := range rule.Resources {
+		if len(gr.ResourceNames) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether the rule applies to a resource request, ignoring
+// its scope.
+func (rule PolicyRule) matches(group, resource, verb string) bool {
+	if len(rule.NonResourceURLs) > 0 && len(rule.Resources) == 0 {
+		return false
+	}
+	if len(rule.Verbs) > 0 && !contains(rule.Verbs, verb) && !contains(rule.Verbs, "*") {
+		return false
+	}
+	if len(rule.Resources) == 0 {
+		return true
+	}
+	for _, gr := range rule.Resources {
+		if gr.Group != group && gr.Group != "*" {
+			continue
+		}
+		if len(gr.Resources) == 0 {
+			return true
+		}
+		for _, pattern := range gr.Resources {
+			if matchResource(pattern, resource) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchResource follows the apiserver matching of resources: "*", "pods",
+// "pods/exec", "pods/*" and "*/scale".
+func matchResource(pattern, resource string) bool {
+	if pattern == "*" || pattern == resource {
+		return true
+	}
+	name, subresource, _ := strings.Cut(resource, "/")
+	if strings.HasSuffix(pattern, "/*") && subresource != "" {
+		return strings.TrimSuffix(pattern, "/*") == name
+	}
+	if strings.HasPrefix(pattern, "*/") && subresource != "" {
+		return strings.TrimPrefix(pattern, "*/") == subresource
+	}
+	return false
+}
+
+func summarize(index int, rule PolicyRule) *Rule {
+	s := &Rule{Index: index, Level: rule.Level, Verbs: rule.Verbs, URLs: rule.NonResourceURLs}
+	if len(s.Verbs) == 0 {
+		s.Verbs = []string{"*"}
+	}
+	for _, gr := range rule.Resources {
+		group := gr.Group
+		if group == "" {
+			group = "core"
+		}
+		if len(gr.Resources) == 0 {
+			s.Resources = append(s.Resources, group+"/*")
+		}
+		for _, resource := range gr.Resources {
+			s.Resources = append(s.Resources, group+"/"+resource)
+		}
+		for _, name := range gr.ResourceNames {
+			s.Scope = append(s.Scope, "resourceName:"+name)
+		}
+	}
+	if len(rule.Resources) == 0 && len(rule.NonResourceURLs) == 0 {
+		s.Resources = []string{"*"}
+	}
+	for _, user := range rule.Users {
+		s.Scope = append(s.Scope, "user:"+user)
+	}
+	for _, group := range rule.UserGroups {
+		s.Scope = append(s.Scope, "group:"+group)
+	}
+	for _, namespace := range rule.Namespaces {
+		s.Scope = append(s.Scope, "namespace:"+namespace)
+	}
+	return s
+}
+
+func concernVerbs(name string) []string {
+	for _, c := range Concerns {
+		if c.Name == name {
+			return c.Verbs
+		}
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,161 @@
+package auditpolicy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func load(t *testing.T, content string) *Policy {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "audit-policy.yaml")
+	require.Nil(t, os.WriteFile(path, []byte(content), 0o600))
+	policy, err := Load(path)
+	require.Nil(t, err)
+	return policy
+}
+
+func TestAnalyze(t *testing.T) {
+	tests := []struct {
+		name         string
+		policy       string
+		passed       bool
+		levels       map[string]string
+		secretBodies []Finding
+		text         string
+	}{
+		{
+			name: "metadata for everything",
+			policy: `apiVersion: audit.k8s.io/v1
+kind: Policy
+rules:
+- level: Metadata
+`,
+			passed: true,
+			levels: map[string]string{"secrets": "Metadata", "configmaps": "Metadata", "tokenreviews": "Metadata", "pod-exec": "Metadata"},
+			text: "**concern: secrets level: Metadata is_compliant: true\n" +
+				"**concern: configmaps level: Metadata is_compliant: true\n" +
+				"**concern: tokenreviews level: Metadata is_compliant: true\n" +
+				"**concern: pod-exec level: Metadata is_compliant: true\n" +
+				"**concern: secret-bodies is_compliant: true",
+		},
+		{
+			name: "missing coverage and secret bodies",
+			policy: `apiVersion: audit.k8s.io/v1
+kind: Policy
+rules:
+- level: None
+  users: ["system:kube-proxy"]
+- level: RequestResponse
+  userGroups: ["system:masters"]
+  resources:
+  - group: ""
+    resources: ["secrets"]
+- level: None
+  verbs: ["get"]
+  resources:
+  - group: ""
+    resources: ["pods/*"]
+- level: Metadata
+  resources:
+  - group: ""
+    resources: ["secrets", "configmaps", "pods/exec"]
+- level: Request
+  verbs: ["create", "update"]
+`,
+			passed: false,
+			levels: map[string]string{"secrets": "Metadata", "configmaps": "Metadata", "tokenreviews": "Request", "pod-exec": "None"},
+			secretBodies: []Finding{
+				{Rule: 1, Verb: "get", Level: "RequestResponse"},
+				{Rule: 1, Verb: "list", Level: "RequestResponse"},
+				{Rule: 1, Verb: "watch", Level: "RequestResponse"},
+				{Rule: 1, Verb: "create", Level: "RequestResponse"},
+				{Rule: 1, Verb: "update", Level: "RequestResponse"},
+				{Rule: 1, Verb: "patch", Level: "RequestResponse"},
+				{Rule: 1, Verb: "delete", Level: "RequestResponse"},
+			},
+		},
+		{
+			name: "nothing logged",
+			policy: `apiVersion: audit.k8s.io/v1
+kind: Policy
+rules:
+- level: None
+  nonResourceURLs: ["/healthz*"]
+- level: RequestResponse
+  resources:
+  - group: ""
+    resources: ["secrets"]
+`,
+			passed: false,
+			levels: map[string]string{"secrets": "RequestResponse", "configmaps": "None", "tokenreviews": "None", "pod-exec": "None"},
+			secretBodies: []Finding{
+				{Rule: 1, Verb: "get", Level: "RequestResponse"},
+				{Rule: 1, Verb: "list", Level: "RequestResponse"},
+				{Rule: 1, Verb: "watch", Level: "RequestResponse"},
+				{Rule: 1, Verb: "create", Level: "RequestResponse"},
+				{Rule: 1, Verb: "update", Level: "RequestResponse"},
+				{Rule: 1, Verb: "patch", Level: "RequestResponse"},
+				{Rule: 1, Verb: "delete", Level: "RequestResponse"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Analyze(load(t, tt.policy))
+			assert.Equal(t, tt.passed, r.Passed)
+			levels := map[string]string{}
+			for _, c := range r.Coverage {
+				levels[c.Concern] = c.Level
+			}
+			assert.Equal(t, tt.levels, levels)
+			secretBodies := []Finding{}
+			for _, f := range r.SecretBodies {
+				secretBodies = append(secretBodies, *f)
+			}
+			if tt.secretBodies == nil {
+				tt.secretBodies = []Finding{}
+			}
+			assert.Equal(t, tt.secretBodies, secretBodies)
+			if tt.text != "" {
+				assert.Equal(t, tt.text, r.Text())
+			}
+		})
+	}
+}
+
+func TestAnalyzeRules(t *testing.T) {
+	r := Analyze(load(t, `apiVersion: audit.k8s.io/v1
+kind: Policy
+rules:
+- level: Metadata
+  namespaces: ["kube-system"]
+  resources:
+  - group: apps
+    resources: ["deployments"]
+    resourceNames: ["coredns"]
+- level: None
+  nonResourceURLs: ["/version"]
+`))
+	assert.Equal(t, []*Rule{
+		{Index: 0, Level: "Metadata", Verbs: []string{"*"}, Resources: []string{"apps/deployments"},
+			Scope: []string{"resourceName:coredns", "namespace:kube-system"}},
+		{Index: 1, Level: "None", Verbs: []string{"*"}, URLs: []string{"/version"}},
+	}, r.Rules)
+	assert.Contains(t, r.Text(), "**concern: pod-exec level: None uncovered_verbs: get,create is_compliant: false")
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "policy.yaml")
+	require.Nil(t, os.WriteFile(path, []byte("kind: Policy\nrules:\n- level: Everything\n"), 0o600))
+	_, err := Load(path)
+	assert.NotNil(t, err)
+
+	require.Nil(t, os.WriteFile(path, []byte("kind: EncryptionConfiguration\n"), 0o600))
+	_, err = Load(path)
+	assert.NotNil(t, err)
+}
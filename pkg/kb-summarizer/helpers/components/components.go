@@ -0,0 +1,361 @@
+// Package components resolves the effective arguments of the control plane
+// components from the running processes, the static pod manifests listed in
+// the kube-bench configuration and the RKE2/K3s configuration files.
+package components
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rancher/security-scan/pkg/kb-summarizer/helpers"
+	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+	k8syaml "sigs.k8s.io/yaml"
+)
+
+const (
+	APIServer         = "apiserver"
+	Scheduler         = "scheduler"
+	ControllerManager = "controllermanager"
+	Etcd              = "etcd"
+
+	// DefaultKubeBenchConfig is the kube-bench configuration of the image.
+	DefaultKubeBenchConfig = "/etc/kube-bench/cfg/config.yaml"
+
+	// SourceProcess marks the arguments read from the running process.
+	SourceProcess = "process"
+	// SourceJournal marks the arguments read from the journal.
+	SourceJournal = "journal"
+
+	// DefaultJournalUnit is the K3s unit, whose journal logs the arguments of
+	// the components it embeds.
+	DefaultJournalUnit = "k3s"
+)
+
+// DefaultDistroConfigs are the RKE2 and K3s configuration files. The files
+// of their config.yaml.d directories are read after them.
+var DefaultDistroConfigs = []string{
+	"/etc/rancher/rke2/config.yaml",
+	"/etc/rancher/k3s/config.yaml",
+}
+
+// Component describes where the arguments of a control plane component are
+// found.
+type Component struct {
+	// Binary is the name of the executable, and of the static pod container.
+	Binary string
+	// Aliases are the names the component has as a hyperkube subcommand.
+	Aliases []string
+	// ConfigKey is the key of the RKE2/K3s configuration passing extra
+	// arguments to the component.
+	ConfigKey string
+	// Sections are the kube-bench configuration sections, as target.component,
+	// whose confs list the static pod manifests.
+	Sections []string
+}
+
+// Components are the control plane components that can be resolved.
+var Components = map[string]*Component{
+	APIServer: {
+		Binary:    "kube-apiserver",
+		Aliases:   []string{"apiserver", "kube-apiserver"},
+		ConfigKey: "kube-apiserver-arg",
+		Sections:  []string{"master.apiserver"},
+	},
+	Scheduler: {
+		Binary:    "kube-scheduler",
+		Aliases:   []string{"scheduler", "kube-scheduler"},
+		ConfigKey: "kube-scheduler-arg",
+		Sections:  []string{"master.scheduler"},
+	},
+	ControllerManager: {
+		Binary:    "kube-controller-manager",
+		Aliases:   []string{"controller-manager", "kube-controller-manager"},
+		ConfigKey: "kube-controller-manager-arg",
+		Sections:  []string{"master.controllermanager"},
+	},
+	Etcd: {
+		Binary:    "etcd",
+		ConfigKey: "etcd-arg",
+		Sections:  []string{"master.etcd", "etcd.etcd"},
+	},
+}
+
+// Options configure Resolve.
+type Options struct {
+	// Root is where the host filesystem is mounted.
+	Root string
+	// ProcDir is searched for the component process when Args is empty.
+	ProcDir string
+	// Args is the command line of the component, without the binary. It
+	// replaces the process lookup, e.g. for k3s, which embeds the components.
+	Args []string
+	// KubeBenchConfig lists the static pod manifests. A missing file is
+	// ignored when it is DefaultKubeBenchConfig.
+	KubeBenchConfig string
+	// DistroConfigs are the RKE2/K3s configuration files.
+	DistroConfigs []string
+	// JournalUnit is the systemd unit whose journal is searched for the last
+	// "Running <component>" line when Args is empty and no process is found,
+	// as K3s runs the components in its own process.
+	JournalUnit string
+}
+
+// Result is the resolved arguments of a component.
+type Result struct {
+	Component string `json:"component"`
+	// Flags are keyed by name, without the leading dashes.
+	Flags map[string]string `json:"flags"`
+	// Sources records where each flag comes from: a file, or SourceProcess.
+	Sources map[string]string `json:"sources"`
+}
+
+// Text renders one sorted --name=value line per flag, which the flag tests
+// of the control files match on as they do on the ps output.
+func (r *Result) Text() string {
+	names := make([]string, 0, len(r.Flags))
+	for name := range r.Flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	lines := make([]string, 0, len(names))
+	for _, name := range names {
+		lines = append(lines, "--"+name+"="+r.Flags[name])
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Resolve returns the arguments of the component. Each source overrides the
+// previous one: the RKE2/K3s configuration files, the static pod manifest,
+// then the running process, or the journal of the unit without a process.
+func Resolve(name string, opts Options) (*Result, error) {
+	component, ok := Components[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown component %q", name)
+	}
+	r := &Result{Component: name, Flags: map[string]string{}, Sources: map[string]string{}}
+
+	for _, config := range opts.DistroConfigs {
+		if err := r.addDistroConfig(opts.Root, config, component.ConfigKey); err != nil {
+			return nil, err
+		}
+	}
+
+	manifests, err := manifestPaths(opts.KubeBenchConfig, component.Sections)
+	if err != nil {
+		return nil, err
+	}
+	for _, manifest := range manifests {
+		args, err := loadManifest(helpers.HostPath(opts.Root, manifest), component.Binary)
+		if err != nil {
+			return nil, err
+		}
+		if args != nil {
+			r.add(args, manifest)
+			break
+		}
+	}
+
+	args := opts.Args
+	if len(args) == 0 {
+		cmdline, err := helpers.FindProcess(opts.ProcDir, component.matches)
+		if err != nil {
+			return nil, err
+		}
+		args = component.trimBinary(cmdline)
+	}
+	if len(args) == 0 && opts.JournalUnit != "" {
+		journal, err := readJournal(opts.JournalUnit)
+		if err != nil {
+			return nil, err
+		}
+		r.add(journalArgs(journal, component.Binary), SourceJournal)
+		return r, nil
+	}
+	r.add(args, SourceProcess)
+	return r, nil
+}
+
+// readJournal returns the messages of the journal of the unit, nothing when
+// journalctl is not installed.
+var readJournal = func(unit string) ([]byte, error) {
+	out, err := exec.Command("journalctl", "-m", "-u", unit, "-o", "cat").Output()
+	if errors.Is(err, exec.ErrNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error reading the journal of %v: %w", unit, err)
+	}
+	return out, nil
+}
+
+// journalArgs returns the arguments of the last "Running <binary>" line of
+// the journal, which K3s logs when it starts an embedded component, e.g.
+//
+//	time="..." level=info msg="Running kube-scheduler --bind-address=127.0.0.1 --profiling=false"
+func journalArgs(journal []byte, binary string) []string {
+	prefix := "Running " + binary + " "
+	var args []string
+	for _, line := range strings.Split(string(journal), "\n") {
+		_, command, found := strings.Cut(line, prefix)
+		if found {
+			args = strings.Fields(strings.TrimSuffix(strings.TrimSpace(command), `"`))
+		}
+	}
+	return args
+}
+
+func (r *Result) add(args []string, source string) {
+	for name, value := range helpers.ParseFlags(args, "feature-gates") {
+		r.Flags[name] = value
+		r.Sources[name] = source
+	}
+}
+
+// addDistroConfig reads the component arguments of an RKE2/K3s configuration
+// file and of its config.yaml.d directory. As in RKE2/K3s, a key suffixed
+// with + appends to the previous files instead of replacing them.
+func (r *Result) addDistroConfig(root, config, key string) error {
+	files := []string{config}
+	dropIns, err := filepath.Glob(helpers.HostPath(root, config+".d/*.yaml"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(dropIns)
+	for _, dropIn := range dropIns {
+		files = append(files, path.Join(config+".d", filepath.Base(dropIn)))
+	}
+
+	var args []string
+	source := ""
+	for _, file := range files {
+		data, err := os.ReadFile(filepath.Clean(helpers.HostPath(root, file)))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		values := map[string]any{}
+		if err := yaml.Unmarshal(data, &values); err != nil {
+			return fmt.Errorf("error parsing %v: %w", file, err)
+		}
+		if v, ok := values[key]; ok {
+			args = toArgs(v)
+			source = file
+		}
+		if v, ok := values[key+"+"]; ok {
+			args = append(args, toArgs(v)...)
+			source = file
+		}
+	}
+	r.add(args, source)
+	return nil
+}
+
+func toArgs(v any) []string {
+	var values []any
+	switch v := v.(type) {
+	case []any:
+		values = v
+	default:
+		values = []any{v}
+	}
+	args := make([]string, 0, len(values))
+	for _, value := range values {
+		args = append(args, "--"+strings.TrimLeft(fmt.Sprint(value), "-"))
+	}
+	return args
+}
+
+// manifestPaths returns the confs of the sections of the kube-bench
+// configuration.
+func manifestPaths(kubeBenchConfig string, sections []string) ([]string, error) {
+	if kubeBenchConfig == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(filepath.Clean(kubeBenchConfig))
+	if os.IsNotExist(err) && kubeBenchConfig == DefaultKubeBenchConfig {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	config := map[string]map[string]any{}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("error parsing %v: %w", kubeBenchConfig, err)
+	}
+	var paths []string
+	for _, section := range sections {
+		target, component, _ := strings.Cut(section, ".")
+		c, ok := config[target][component].(map[string]any)
+		if !ok {
+			continue
+		}
+		confs, _ := c["confs"].([]any)
+		for _, conf := range confs {
+			paths = append(paths, fmt.Sprint(conf))
+		}
+	}
+	return paths, nil
+}
+
+// loadManifest returns the arguments of the container named binary in a
+// static pod manifest, or nil when the file does not exist or is not a pod.
+func loadManifest(file, binary string) ([]string, error) {
+	data, err := os.ReadFile(filepath.Clean(file))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	pod := &corev1.Pod{}
+	if err := k8syaml.Unmarshal(data, pod); err != nil || pod.Kind != "Pod" {
+		// the confs also list flat configuration files
+		return nil, nil
+	}
+	for _, c := range pod.Spec.Containers {
+		if c.Name != binary && (len(c.Command) == 0 || filepath.Base(c.Command[0]) != binary) {
+			continue
+		}
+		args := append([]string{}, c.Command...)
+		if len(args) > 0 && filepath.Base(args[0]) == binary {
+			args = args[1:]
+		}
+		return append(args, c.Args...), nil
+	}
+	return nil, nil
+}
+
+func (c *Component) matches(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	base := filepath.Base(args[0])
+	if base == c.Binary {
+		return true
+	}
+	return base == "hyperkube" && len(args) > 1 && contains(c.Aliases, args[1])
+}
+
+func (c *Component) trimBinary(cmdline []string) []string {
+	if len(cmdline) == 0 {
+		return nil
+	}
+	if filepath.Base(cmdline[0]) == "hyperkube" {
+		return cmdline[2:]
+	}
+	return cmdline[1:]
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
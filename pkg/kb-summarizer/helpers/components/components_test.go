@@ -0,0 +1,145 @@
+package components
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.Nil(t, os.MkdirAll(filepath.Dir(path), 0o750))
+	require.Nil(t, os.WriteFile(path, []byte(content), 0o600))
+}
+
+func TestResolve(t *testing.T) {
+	root := t.TempDir()
+	kubeBenchConfig := filepath.Join(t.TempDir(), "config.yaml")
+	writeFile(t, kubeBenchConfig, `master:
+  apiserver:
+    confs:
+      - /etc/kubernetes/manifests/kube-apiserver.yaml
+      - /var/lib/rancher/rke2/agent/pod-manifests/kube-apiserver.yaml
+  etcd:
+    confs:
+      - /var/lib/rancher/rke2/server/db/etcd/config
+etcd:
+  etcd:
+    confs:
+      - /var/lib/rancher/rke2/agent/pod-manifests/etcd.yaml
+`)
+	writeFile(t, filepath.Join(root, "etc/rancher/rke2/config.yaml"), `kube-apiserver-arg:
+  - "audit-log-maxage=10"
+  - "profiling=true"
+etcd-arg: "heartbeat-interval=500"
+`)
+	writeFile(t, filepath.Join(root, "etc/rancher/rke2/config.yaml.d/50-audit.yaml"), `kube-apiserver-arg+:
+  - "audit-log-maxbackup=10"
+`)
+	writeFile(t, filepath.Join(root, "var/lib/rancher/rke2/agent/pod-manifests/kube-apiserver.yaml"), `apiVersion: v1
+kind: Pod
+metadata:
+  name: kube-apiserver
+spec:
+  containers:
+  - name: kube-apiserver
+    command:
+    - kube-apiserver
+    args:
+    - --audit-log-maxage=30
+    - --profiling=false
+    - --anonymous-auth=false
+`)
+	writeFile(t, filepath.Join(root, "var/lib/rancher/rke2/server/db/etcd/config"), "name: node-1\n")
+	writeFile(t, filepath.Join(root, "var/lib/rancher/rke2/agent/pod-manifests/etcd.yaml"), `apiVersion: v1
+kind: Pod
+spec:
+  containers:
+  - name: etcd
+    command: ["etcd", "--config-file=/var/lib/rancher/rke2/server/db/etcd/config"]
+`)
+	opts := Options{
+		Root:            root,
+		ProcDir:         t.TempDir(),
+		KubeBenchConfig: kubeBenchConfig,
+		DistroConfigs:   DefaultDistroConfigs,
+	}
+
+	r, err := Resolve(APIServer, opts)
+	require.Nil(t, err)
+	assert.Equal(t, map[string]string{
+		"anonymous-auth":      "false",
+		"audit-log-maxage":    "30",
+		"audit-log-maxbackup": "10",
+		"profiling":           "false",
+	}, r.Flags)
+	assert.Equal(t, "/etc/rancher/rke2/config.yaml.d/50-audit.yaml", r.Sources["audit-log-maxbackup"])
+	assert.Equal(t, "/var/lib/rancher/rke2/agent/pod-manifests/kube-apiserver.yaml", r.Sources["profiling"])
+	assert.Equal(t, "--anonymous-auth=false\n--audit-log-maxage=30\n--audit-log-maxbackup=10\n--profiling=false", r.Text())
+
+	// the running process is what is effective
+	opts.Args = []string{"--profiling", "--feature-gates=A=true", "--feature-gates", "B=false"}
+	r, err = Resolve(APIServer, opts)
+	require.Nil(t, err)
+	assert.Equal(t, "true", r.Flags["profiling"])
+	assert.Equal(t, "A=true,B=false", r.Flags["feature-gates"])
+	assert.Equal(t, SourceProcess, r.Sources["profiling"])
+	opts.Args = nil
+
+	r, err = Resolve(Etcd, opts)
+	require.Nil(t, err)
+	assert.Equal(t, map[string]string{
+		"config-file":        "/var/lib/rancher/rke2/server/db/etcd/config",
+		"heartbeat-interval": "500",
+	}, r.Flags)
+
+	_, err = Resolve("kubelet", opts)
+	assert.NotNil(t, err)
+}
+
+func TestResolveProcess(t *testing.T) {
+	procDir := t.TempDir()
+	writeFile(t, filepath.Join(procDir, "7", "cmdline"), "kube-scheduler\x00--profiling=false\x00--bind-address\x00127.0.0.1\x00")
+	writeFile(t, filepath.Join(procDir, "8", "cmdline"), "/hyperkube\x00controller-manager\x00--terminated-pod-gc-threshold=10\x00")
+
+	r, err := Resolve(Scheduler, Options{ProcDir: procDir})
+	require.Nil(t, err)
+	assert.Equal(t, map[string]string{"profiling": "false", "bind-address": "127.0.0.1"}, r.Flags)
+
+	r, err = Resolve(ControllerManager, Options{ProcDir: procDir})
+	require.Nil(t, err)
+	assert.Equal(t, map[string]string{"terminated-pod-gc-threshold": "10"}, r.Flags)
+}
+
+func TestResolveJournal(t *testing.T) {
+	defer func(f func(string) ([]byte, error)) { readJournal = f }(readJournal)
+	readJournal = func(unit string) ([]byte, error) {
+		assert.Equal(t, DefaultJournalUnit, unit)
+		return []byte(`time="2024-05-01T10:00:00Z" level=info msg="Running kube-scheduler --bind-address=0.0.0.0 --profiling=true"
+time="2024-05-01T10:00:01Z" level=info msg="Running kube-controller-manager --terminated-pod-gc-threshold=10"
+time="2024-05-01T11:00:00Z" level=info msg="Running kube-scheduler --bind-address=127.0.0.1 --profiling=false"
+`), nil
+	}
+	opts := Options{ProcDir: t.TempDir(), JournalUnit: DefaultJournalUnit}
+
+	// the last start of the component is what is effective
+	r, err := Resolve(Scheduler, opts)
+	require.Nil(t, err)
+	assert.Equal(t, map[string]string{"profiling": "false", "bind-address": "127.0.0.1"}, r.Flags)
+	assert.Equal(t, SourceJournal, r.Sources["profiling"])
+
+	r, err = Resolve(Etcd, opts)
+	require.Nil(t, err)
+	assert.Empty(t, r.Flags)
+
+	// a running process is preferred
+	procDir := t.TempDir()
+	writeFile(t, filepath.Join(procDir, "7", "cmdline"), "kube-scheduler\x00--profiling=true\x00")
+	opts.ProcDir = procDir
+	r, err = Resolve(Scheduler, opts)
+	require.Nil(t, err)
+	assert.Equal(t, map[string]string{"profiling": "true"}, r.Flags)
+}
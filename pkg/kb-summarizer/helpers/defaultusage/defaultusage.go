@@ -0,0 +1,307 @@
+// Package defaultusage checks that the default ServiceAccounts and the default
+// namespace are not used (CIS 5.1.5 and 5.7.4). It replaces
+// check_for_default_sa.sh and check_for_default_ns.sh, listing every object
+// kind once instead of querying the cluster per namespace.
+package defaultusage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rancher/security-scan/pkg/kb-summarizer/helpers"
+	"github.com/rancher/security-scan/pkg/kb-summarizer/helpers/kube"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	DefaultServiceAccount = "default"
+	DefaultNamespace      = metav1.NamespaceDefault
+
+	allServiceAccountsGroup = "system:serviceaccounts"
+	// podSecurityPolicies is the only resource the script allowed the
+	// default service account to be granted.
+	podSecurityPolicies = "podsecuritypolicies"
+)
+
+// DefaultNamespaceExemptions are the objects allowed in the default namespace,
+// as Kind/name patterns.
+var DefaultNamespaceExemptions = []string{"Service/kubernetes"}
+
+// Violation identifies an object that fails a check and why.
+type Violation struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	Reason    string `json:"reason"`
+}
+
+// Result is the outcome of either check.
+type Result struct {
+	Passed     bool         `json:"passed"`
+	Violations []*Violation `json:"violations"`
+}
+
+func (r *Result) Text() string {
+	return helpers.BoolText(r.Passed)
+}
+
+func (r *Result) add(kind, namespace, name, format string, args ...any) {
+	r.Violations = append(r.Violations, &Violation{
+		Kind:      kind,
+		Namespace: namespace,
+		Name:      name,
+		Reason:    fmt.Sprintf(format, args...),
+	})
+}
+
+func (r *Result) finish() *Result {
+	sort.SliceStable(r.Violations, func(i, j int) bool {
+		a, b := r.Violations[i], r.Violations[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+	r.Passed = len(r.Violations) == 0
+	if r.Violations == nil {
+		r.Violations = []*Violation{}
+	}
+	return r
+}
+
+// ServiceAccountOptions configure CheckServiceAccounts.
+type ServiceAccountOptions struct {
+	// ExemptNamespaces are namespace patterns whose default service account
+	// is not checked, e.g. kube-system.
+	ExemptNamespaces []string
+	// ExemptBindings are (Cluster)RoleBinding name patterns that may grant
+	// permissions to default service accounts.
+	ExemptBindings []string
+}
+
+// CheckServiceAccounts reports default service accounts that automount their
+// token, pods that force the token of a default service account to be
+// mounted, and bindings granting default service accounts any permission.
+func CheckServiceAccounts(ctx context.Context, client kubernetes.Interface, opts ServiceAccountOptions) (*Result, error) {
+	r := &Result{}
+	exempt := func(namespace string) bool {
+		return kube.MatchAny(opts.ExemptNamespaces, namespace)
+	}
+
+	sas, err := client.CoreV1().ServiceAccounts(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error listing service accounts: %w", err)
+	}
+	for _, sa := range sas.Items {
+		if sa.Name != DefaultServiceAccount || exempt(sa.Namespace) {
+			continue
+		}
+		if sa.AutomountServiceAccountToken == nil || *sa.AutomountServiceAccountToken {
+			r.add("ServiceAccount", sa.Namespace, sa.Name, "automountServiceAccountToken is not false")
+		}
+	}
+
+	pods, err := client.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error listing pods: %w", err)
+	}
+	for _, pod := range pods.Items {
+		if exempt(pod.Namespace) {
+			continue
+		}
+		sa := pod.Spec.ServiceAccountName
+		if sa != "" && sa != DefaultServiceAccount {
+			continue
+		}
+		if pod.Spec.AutomountServiceAccountToken != nil && *pod.Spec.AutomountServiceAccountToken {
+			r.add("Pod", pod.Namespace, pod.Name, "mounts the token of the default service account")
+		}
+	}
+
+	roles, err := client.RbacV1().Roles(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error listing roles: %w", err)
+	}
+	roleRules := map[string][]rbacv1.PolicyRule{}
+	for _, role := range roles.Items {
+		roleRules["Role/"+role.Namespace+"/"+role.Name] = role.Rules
+	}
+	clusterRoles, err := client.RbacV1().ClusterRoles().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error listing cluster roles: %w", err)
+	}
+	for _, role := range clusterRoles.Items {
+		roleRules["ClusterRole//"+role.Name] = role.Rules
+	}
+	rulesOf := func(namespace string, ref rbacv1.RoleRef) []rbacv1.PolicyRule {
+		if ref.Kind == "ClusterRole" {
+			namespace = ""
+		}
+		return roleRules[ref.Kind+"/"+namespace+"/"+ref.Name]
+	}
+
+	rbs, err := client.RbacV1().RoleBindings(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error listing role bindings: %w", err)
+	}
+	for _, rb := range rbs.Items {
+		if kube.MatchAny(opts.ExemptBindings, rb.Name) {
+			continue
+		}
+		if subject := defaultSubject(rb.Namespace, rb.Subjects, exempt); subject != "" && grantsPermissions(rulesOf(rb.Namespace, rb.RoleRef)) {
+			r.add("RoleBinding", rb.Namespace, rb.Name, "grants %v %v to %v", rb.RoleRef.Kind, rb.RoleRef.Name, subject)
+		}
+	}
+	crbs, err := client.RbacV1().ClusterRoleBindings().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error listing cluster role bindings: %w", err)
+	}
+	for _, crb := range crbs.Items {
+		if kube.MatchAny(opts.ExemptBindings, crb.Name) {
+			continue
+		}
+		if subject := defaultSubject("", crb.Subjects, exempt); subject != "" && grantsPermissions(rulesOf("", crb.RoleRef)) {
+			r.add("ClusterRoleBinding", "", crb.Name, "grants %v %v to %v", crb.RoleRef.Kind, crb.RoleRef.Name, subject)
+		}
+	}
+	return r.finish(), nil
+}
+
+// defaultSubject returns the first subject that includes a default service
+// account of a non exempt namespace, or "" if there is none.
+func defaultSubject(bindingNamespace string, subjects []rbacv1.Subject, exempt func(string) bool) string {
+	for _, s := range subjects {
+		switch s.Kind {
+		case rbacv1.ServiceAccountKind:
+			namespace := s.Namespace
+			if namespace == "" {
+				namespace = bindingNamespace
+			}
+			if s.Name == DefaultServiceAccount && !exempt(namespace) {
+				return "ServiceAccount " + namespace + "/" + s.Name
+			}
+		case rbacv1.GroupKind:
+			if s.Name == allServiceAccountsGroup {
+				return "Group " + s.Name
+			}
+			if namespace, ok := strings.CutPrefix(s.Name, allServiceAccountsGroup+":"); ok && !exempt(namespace) {
+				return "Group " + s.Name
+			}
+		}
+	}
+	return ""
+}
+
+func grantsPermissions(rules []rbacv1.PolicyRule) bool {
+	for _, rule := range rules {
+		if len(rule.NonResourceURLs) > 0 {
+			return true
+		}
+		for _, resource := range rule.Resources {
+			if resource != podSecurityPolicies {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// NamespaceOptions configure CheckNamespace.
+type NamespaceOptions struct {
+	// Namespace to check, the default namespace when empty.
+	Namespace string
+	// Exemptions are Kind/name patterns of objects allowed in the namespace.
+	Exemptions []string
+}
+
+// CheckNamespace reports the workloads and services found in the default
+// namespace, the objects "kubectl get all" would list.
+func CheckNamespace(ctx context.Context, client kubernetes.Interface, opts NamespaceOptions) (*Result, error) {
+	ns := opts.Namespace
+	if ns == "" {
+		ns = DefaultNamespace
+	}
+	exemptions := opts.Exemptions
+	if exemptions == nil {
+		exemptions = DefaultNamespaceExemptions
+	}
+	r := &Result{}
+	add := func(kind, name string) {
+		if !kube.MatchAny(exemptions, kind+"/"+name) {
+			r.add(kind, ns, name, "found in the %v namespace", ns)
+		}
+	}
+	listOpts := metav1.ListOptions{}
+
+	pods, err := client.CoreV1().Pods(ns).List(ctx, listOpts)
+	if err != nil {
+		return nil, fmt.Errorf("error listing pods: %w", err)
+	}
+	for _, o := range pods.Items {
+		add("Pod", o.Name)
+	}
+	services, err := client.CoreV1().Services(ns).List(ctx, listOpts)
+	if err != nil {
+		return nil, fmt.Errorf("error listing services: %w", err)
+	}
+	for _, o := range services.Items {
+		add("Service", o.Name)
+	}
+	rcs, err := client.CoreV1().ReplicationControllers(ns).List(ctx, listOpts)
+	if err != nil {
+		return nil, fmt.Errorf("error listing replication controllers: %w", err)
+	}
+	for _, o := range rcs.Items {
+		add("ReplicationController", o.Name)
+	}
+	deployments, err := client.AppsV1().Deployments(ns).List(ctx, listOpts)
+	if err != nil {
+		return nil, fmt.Errorf("error listing deployments: %w", err)
+	}
+	for _, o := range deployments.Items {
+		add("Deployment", o.Name)
+	}
+	replicaSets, err := client.AppsV1().ReplicaSets(ns).List(ctx, listOpts)
+	if err != nil {
+		return nil, fmt.Errorf("error listing replica sets: %w", err)
+	}
+	for _, o := range replicaSets.Items {
+		add("ReplicaSet", o.Name)
+	}
+	daemonSets, err := client.AppsV1().DaemonSets(ns).List(ctx, listOpts)
+	if err != nil {
+		return nil, fmt.Errorf("error listing daemon sets: %w", err)
+	}
+	for _, o := range daemonSets.Items {
+		add("DaemonSet", o.Name)
+	}
+	statefulSets, err := client.AppsV1().StatefulSets(ns).List(ctx, listOpts)
+	if err != nil {
+		return nil, fmt.Errorf("error listing stateful sets: %w", err)
+	}
+	for _, o := range statefulSets.Items {
+		add("StatefulSet", o.Name)
+	}
+	jobs, err := client.BatchV1().Jobs(ns).List(ctx, listOpts)
+	if err != nil {
+		return nil, fmt.Errorf("error listing jobs: %w", err)
+	}
+	for _, o := range jobs.Items {
+		add("Job", o.Name)
+	}
+	cronJobs, err := client.BatchV1().CronJobs(ns).List(ctx, listOpts)
+	if err != nil {
+		return nil, fmt.Errorf("error listing cron jobs: %w", err)
+	}
+	for _, o := range cronJobs.Items {
+		add("CronJob", o.Name)
+	}
+	return r.finish(), nil
+}
@@ -0,0 +1,102 @@
+package defaultusage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func serviceAccount(namespace string, automount *bool) runtime.Object {
+	return &corev1.ServiceAccount{
+		ObjectMeta:                   metav1.ObjectMeta{Name: DefaultServiceAccount, Namespace: namespace},
+		AutomountServiceAccountToken: automount,
+	}
+}
+
+func TestCheckServiceAccounts(t *testing.T) {
+	no, yes := false, true
+	objects := []runtime.Object{
+		serviceAccount("kube-system", nil),
+		serviceAccount("app", &no),
+		serviceAccount("legacy", &yes),
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "app"},
+			Spec:       corev1.PodSpec{AutomountServiceAccountToken: &yes},
+		},
+		&rbacv1.ClusterRole{
+			ObjectMeta: metav1.ObjectMeta{Name: "psp"},
+			Rules:      []rbacv1.PolicyRule{{Verbs: []string{"use"}, Resources: []string{"podsecuritypolicies"}}},
+		},
+		&rbacv1.Role{
+			ObjectMeta: metav1.ObjectMeta{Name: "reader", Namespace: "app"},
+			Rules:      []rbacv1.PolicyRule{{Verbs: []string{"get"}, Resources: []string{"configmaps"}}},
+		},
+		&rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "psp-all"},
+			RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "psp"},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "system:serviceaccounts"}},
+		},
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "reader", Namespace: "app"},
+			RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "reader"},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: "default"}},
+		},
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "reader-sa", Namespace: "app"},
+			RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "reader"},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: "app", Namespace: "app"}},
+		},
+	}
+
+	client := fake.NewClientset(objects...)
+	r, err := CheckServiceAccounts(context.Background(), client, ServiceAccountOptions{})
+	require.Nil(t, err)
+	assert.False(t, r.Passed)
+	var got []string
+	for _, v := range r.Violations {
+		got = append(got, v.Kind+"/"+v.Namespace+"/"+v.Name)
+	}
+	assert.Equal(t, []string{
+		"Pod/app/web",
+		"RoleBinding/app/reader",
+		"ServiceAccount/kube-system/default",
+		"ServiceAccount/legacy/default",
+	}, got)
+
+	r, err = CheckServiceAccounts(context.Background(), client, ServiceAccountOptions{
+		ExemptNamespaces: []string{"kube-system", "legacy", "app"},
+	})
+	require.Nil(t, err)
+	assert.True(t, r.Passed)
+	assert.Equal(t, "true", r.Text())
+}
+
+func TestCheckNamespace(t *testing.T) {
+	objects := []runtime.Object{
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "kubernetes", Namespace: "default"}},
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "nginx", Namespace: "default"}},
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "nginx", Namespace: "app"}},
+	}
+	client := fake.NewClientset(objects...)
+
+	r, err := CheckNamespace(context.Background(), client, NamespaceOptions{})
+	require.Nil(t, err)
+	assert.False(t, r.Passed)
+	require.Len(t, r.Violations, 1)
+	assert.Equal(t, "Deployment", r.Violations[0].Kind)
+	assert.Equal(t, "nginx", r.Violations[0].Name)
+
+	r, err = CheckNamespace(context.Background(), client, NamespaceOptions{
+		Exemptions: append([]string{"Deployment/nginx"}, DefaultNamespaceExemptions...),
+	})
+	require.Nil(t, err)
+	assert.True(t, r.Passed)
+}
@@ -0,0 +1,233 @@
+// Package encryption analyzes the EncryptionConfiguration passed to the
+// kube-apiserver through --encryption-provider-config.
+package encryption
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rancher/security-scan/pkg/kb-summarizer/helpers"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	ProviderAESCBC    = "aescbc"
+	ProviderAESGCM    = "aesgcm"
+	ProviderSecretbox = "secretbox"
+	ProviderKMS       = "kms"
+	ProviderIdentity  = "identity"
+
+	ConfigFlag      = "encryption-provider-config"
+	KMSAPIVersionV2 = "v2"
+)
+
+// DefaultProviders are the provider types accepted as the first, i.e. writing,
+// provider. kms is only accepted with apiVersion v2.
+var DefaultProviders = []string{ProviderAESCBC, ProviderAESGCM, ProviderSecretbox, ProviderKMS}
+
+// DefaultResources must always be encrypted.
+var DefaultResources = []string{"secrets"}
+
+// DefaultConfigFiles are tried, in order, when the apiserver command line does
+// not reveal where the configuration lives.
+var DefaultConfigFiles = []string{
+	"/var/lib/rancher/rke2/server/cred/encryption-config.json",
+	"/var/lib/rancher/k3s/server/cred/encryption-config.json",
+	"/etc/kubernetes/ssl/encryption.yaml",
+}
+
+// Config mirrors the subset of apiserver.config.k8s.io/v1 EncryptionConfiguration
+// the analyzer needs.
+type Config struct {
+	Kind      string           `yaml:"kind"`
+	Resources []ResourceConfig `yaml:"resources"`
+}
+
+type ResourceConfig struct {
+	Resources []string         `yaml:"resources"`
+	Providers []ProviderConfig `yaml:"providers"`
+}
+
+// ProviderConfig holds one provider entry. Only the presence of a key matters,
+// apart from the kms apiVersion.
+type ProviderConfig struct {
+	AESCBC    *struct{} `yaml:"aescbc"`
+	AESGCM    *struct{} `yaml:"aesgcm"`
+	Secretbox *struct{} `yaml:"secretbox"`
+	Identity  *struct{} `yaml:"identity"`
+	KMS       *struct {
+		APIVersion string `yaml:"apiVersion"`
+		Name       string `yaml:"name"`
+	} `yaml:"kms"`
+}
+
+// Type returns the provider type, e.g. "aescbc" or "kms".
+func (p ProviderConfig) Type() string {
+	switch {
+	case p.AESCBC != nil:
+		return ProviderAESCBC
+	case p.AESGCM != nil:
+		return ProviderAESGCM
+	case p.Secretbox != nil:
+		return ProviderSecretbox
+	case p.KMS != nil:
+		return ProviderKMS
+	case p.Identity != nil:
+		return ProviderIdentity
+	}
+	return ""
+}
+
+// String returns the provider type, including the API version for kms.
+func (p ProviderConfig) String() string {
+	if p.KMS != nil {
+		v := p.KMS.APIVersion
+		if v == "" {
+			v = "v1"
+		}
+		return ProviderKMS + v
+	}
+	return p.Type()
+}
+
+// ResourceResult describes how one required resource is encrypted.
+type ResourceResult struct {
+	Resource      string   `json:"resource"`
+	Covered       bool     `json:"covered"`
+	MatchedBy     string   `json:"matchedBy,omitempty"`
+	Providers     []string `json:"providers,omitempty"`
+	FirstProvider string   `json:"firstProvider,omitempty"`
+	Approved      bool     `json:"approved"`
+	IdentityFirst bool     `json:"identityFirst"`
+}
+
+// Result is the outcome of the analysis.
+type Result struct {
+	Passed     bool              `json:"passed"`
+	ConfigFile string            `json:"configFile"`
+	Approved   []string          `json:"approvedProviders"`
+	Resources  []*ResourceResult `json:"resources"`
+	Findings   []string          `json:"findings,omitempty"`
+}
+
+func (r *Result) Text() string {
+	return helpers.BoolText(r.Passed)
+}
+
+// Load reads and parses an EncryptionConfiguration, in YAML or JSON.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("error reading file %v: %w", path, err)
+	}
+	config := &Config{}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("error unmarshalling encryption config %v: %w", path, err)
+	}
+	if config.Kind != "EncryptionConfiguration" {
+		return nil, fmt.Errorf("unexpected kind %q in %v", config.Kind, path)
+	}
+	return config, nil
+}
+
+// ConfigFile works out where the EncryptionConfiguration lives, from the
+// kube-apiserver command line or else the first of DefaultConfigFiles that
+// exists under root.
+func ConfigFile(root, procDir string) (string, error) {
+	args, err := helpers.FindProcess(procDir, func(args []string) bool {
+		_, ok := helpers.FlagValue(args, ConfigFlag)
+		return ok && strings.Contains(strings.Join(args, " "), "apiserver")
+	})
+	if err != nil {
+		return "", err
+	}
+	if path, _ := helpers.FlagValue(args, ConfigFlag); path != "" {
+		return helpers.HostPath(root, path), nil
+	}
+	for _, path := range DefaultConfigFiles {
+		path = helpers.HostPath(root, path)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("unable to find the encryption provider config")
+}
+
+// Analyze checks that every resource in resources is covered by config and
+// that its first provider is one of approved.
+func Analyze(config *Config, resources, approved []string) *Result {
+	if len(resources) == 0 {
+		resources = DefaultResources
+	}
+	if len(approved) == 0 {
+		approved = DefaultProviders
+	}
+	r := &Result{Passed: true, Approved: approved}
+	for _, resource := range resources {
+		rr := &ResourceResult{Resource: resource}
+		r.Resources = append(r.Resources, rr)
+
+		rc, matchedBy := config.find(resource)
+		if rc == nil {
+			r.fail("%v is not covered by the encryption config", resource)
+			continue
+		}
+		rr.Covered = true
+		rr.MatchedBy = matchedBy
+		for _, p := range rc.Providers {
+			rr.Providers = append(rr.Providers, p.String())
+		}
+		if len(rc.Providers) == 0 {
+			r.fail("%v has no providers", resource)
+			continue
+		}
+		first := rc.Providers[0]
+		rr.FirstProvider = first.String()
+		rr.IdentityFirst = first.Type() == ProviderIdentity
+		rr.Approved = isApproved(first, approved)
+		switch {
+		case rr.IdentityFirst:
+			r.fail("%v is stored unencrypted, identity is the first provider", resource)
+		case !rr.Approved:
+			r.fail("%v is encrypted with %v, which is not one of %v", resource, rr.FirstProvider, strings.Join(approved, ","))
+		}
+	}
+	return r
+}
+
+// find returns the first resource config matching resource, in the same order
+// the apiserver evaluates them, along with the entry that matched.
+func (c *Config) find(resource string) (*ResourceConfig, string) {
+	group := ""
+	if i := strings.Index(resource, "."); i >= 0 {
+		group = resource[i+1:]
+	}
+	for i := range c.Resources {
+		for _, entry := range c.Resources[i].Resources {
+			if entry == resource || entry == "*."+group || entry == "*.*" {
+				return &c.Resources[i], entry
+			}
+		}
+	}
+	return nil, ""
+}
+
+func isApproved(p ProviderConfig, approved []string) bool {
+	for _, a := range approved {
+		if a != p.Type() {
+			continue
+		}
+		if p.KMS != nil && p.KMS.APIVersion != KMSAPIVersionV2 {
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+func (r *Result) fail(format string, args ...any) {
+	r.Passed = false
+	r.Findings = append(r.Findings, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,170 @@
+package encryption
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyze(t *testing.T) {
+	tests := []struct {
+		name          string
+		config        string
+		resources     []string
+		approved      []string
+		passed        bool
+		firstProvider string
+		findings      int
+	}{
+		{
+			name: "aescbc first",
+			config: `
+apiVersion: apiserver.config.k8s.io/v1
+kind: EncryptionConfiguration
+resources:
+  - resources: [secrets]
+    providers:
+      - aescbc:
+          keys:
+            - name: key1
+              secret: c2VjcmV0IGlzIHNlY3VyZQ==
+      - identity: {}
+`,
+			passed:        true,
+			firstProvider: "aescbc",
+		},
+		{
+			name: "identity ahead of aescbc",
+			config: `
+kind: EncryptionConfiguration
+resources:
+  - resources: [secrets]
+    providers:
+      - identity: {}
+      - aescbc:
+          keys:
+            - name: key1
+              secret: c2VjcmV0IGlzIHNlY3VyZQ==
+`,
+			passed:        false,
+			firstProvider: "identity",
+			findings:      1,
+		},
+		{
+			name: "rke2 json config",
+			config: `{"kind":"EncryptionConfiguration","apiVersion":"apiserver.config.k8s.io/v1",
+"resources":[{"resources":["secrets"],"providers":[{"aescbc":{"keys":[{"name":"aescbckey","secret":"x"}]}},{"identity":{}}]}]}`,
+			passed:        true,
+			firstProvider: "aescbc",
+		},
+		{
+			name: "kms v1 is not approved",
+			config: `
+kind: EncryptionConfiguration
+resources:
+  - resources: [secrets]
+    providers:
+      - kms:
+          name: myKmsPlugin
+          endpoint: unix:///tmp/socketfile.sock
+`,
+			passed:        false,
+			firstProvider: "kmsv1",
+			findings:      1,
+		},
+		{
+			name: "kms v2 is approved",
+			config: `
+kind: EncryptionConfiguration
+resources:
+  - resources: [secrets]
+    providers:
+      - kms:
+          apiVersion: v2
+          name: myKmsPlugin
+          endpoint: unix:///tmp/socketfile.sock
+`,
+			passed:        true,
+			firstProvider: "kmsv2",
+		},
+		{
+			name: "secrets not covered",
+			config: `
+kind: EncryptionConfiguration
+resources:
+  - resources: [configmaps]
+    providers:
+      - secretbox: {}
+`,
+			passed:   false,
+			findings: 1,
+		},
+		{
+			name: "core group wildcard",
+			config: `
+kind: EncryptionConfiguration
+resources:
+  - resources: ["*."]
+    providers:
+      - aesgcm: {}
+`,
+			resources:     []string{"secrets", "configmaps"},
+			passed:        true,
+			firstProvider: "aesgcm",
+		},
+		{
+			name: "first matching entry wins",
+			config: `
+kind: EncryptionConfiguration
+resources:
+  - resources: [secrets]
+    providers:
+      - identity: {}
+  - resources: ["*.*"]
+    providers:
+      - aescbc: {}
+`,
+			passed:        false,
+			firstProvider: "identity",
+			findings:      1,
+		},
+		{
+			name: "restricted approved providers",
+			config: `
+kind: EncryptionConfiguration
+resources:
+  - resources: [secrets]
+    providers:
+      - secretbox: {}
+`,
+			approved:      []string{"aescbc"},
+			passed:        false,
+			firstProvider: "secretbox",
+			findings:      1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "encryption.yaml")
+			require.Nil(t, os.WriteFile(path, []byte(tt.config), 0600))
+			config, err := Load(path)
+			require.Nil(t, err)
+
+			r := Analyze(config, tt.resources, tt.approved)
+			assert.Equal(t, tt.passed, r.Passed)
+			assert.Equal(t, tt.passed, r.Text() == "true")
+			assert.Len(t, r.Findings, tt.findings)
+			assert.Equal(t, tt.firstProvider, r.Resources[0].FirstProvider)
+		})
+	}
+}
+
+func TestLoadRejectsOtherKinds(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.yaml")
+	require.Nil(t, os.WriteFile(path, []byte("kind: Policy\n"), 0600))
+	_, err := Load(path)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,231 @@
+// Package files implements the file permission and ownership helpers that
+// used to live in check_files_permissions.sh, check_files_owner_in_dir.sh,
+// check_cafile_permissions.sh and check_cafile_ownership.sh.
+package files
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rancher/security-scan/pkg/kb-summarizer/helpers"
+)
+
+const (
+	RootOwner = "root:root"
+	EtcdOwner = "etcd:etcd"
+
+	etcdFilePrefix = "kube-etcd-"
+)
+
+// DefaultPermissions are accepted when no explicit permission is requested.
+var DefaultPermissions = []string{"644", "640", "600"}
+
+// negatedGlobRe matches the bash extglob form "prefix!(pattern)suffix".
+var negatedGlobRe = regexp.MustCompile(`^(.*)!\(([^)]*)\)(.*)$`)
+
+// Info describes the mode and ownership of a single file.
+type Info struct {
+	Path  string `json:"path"`
+	Mode  string `json:"mode"`
+	Owner string `json:"owner"`
+}
+
+// Result is the outcome of a permission or ownership check over a set of files.
+type Result struct {
+	Passed    bool     `json:"passed"`
+	Expected  []string `json:"expected"`
+	Files     []*Info  `json:"files"`
+	Offending []*Info  `json:"offending,omitempty"`
+}
+
+func (r *Result) Text() string {
+	return helpers.BoolText(r.Passed)
+}
+
+// ModeResult reports the permissions of a single file as "permissions=<mode>".
+type ModeResult struct {
+	*Info
+}
+
+func (r *ModeResult) Text() string {
+	return fmt.Sprintf("permissions=%s", r.Mode)
+}
+
+// OwnerResult reports the ownership of a single file as "<user>:<group>".
+type OwnerResult struct {
+	*Info
+}
+
+func (r *OwnerResult) Text() string {
+	return r.Owner
+}
+
+// Stat returns the mode and ownership of path.
+func Stat(path string) (*Info, error) {
+	fi, err := os.Stat(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("error getting file info of %v: %w", path, err)
+	}
+	return &Info{
+		Path:  path,
+		Mode:  octalMode(fi.Mode()),
+		Owner: owner(fi),
+	}, nil
+}
+
+// octalMode formats m the same way "stat -c %a" does.
+func octalMode(m fs.FileMode) string {
+	mode := uint64(m.Perm())
+	if m&fs.ModeSetuid != 0 {
+		mode |= 0o4000
+	}
+	if m&fs.ModeSetgid != 0 {
+		mode |= 0o2000
+	}
+	if m&fs.ModeSticky != 0 {
+		mode |= 0o1000
+	}
+	return strconv.FormatUint(mode, 8)
+}
+
+// Expand resolves pattern below root into the list of matching paths. A
+// directory expands to the files directly inside it, and the last path element
+// may use the bash extglob negation "!(pattern)", e.g. "/etc/ssl/!(*key).pem".
+func Expand(root, pattern string) ([]string, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("no file pattern specified")
+	}
+	pattern = helpers.HostPath(root, pattern)
+	if fi, err := os.Stat(pattern); err == nil && fi.IsDir() {
+		pattern = filepath.Join(pattern, "*")
+	}
+
+	dir, base := filepath.Split(pattern)
+	include, exclude := base, ""
+	if m := negatedGlobRe.FindStringSubmatch(base); m != nil {
+		include = m[1] + "*" + m[3]
+		exclude = m[1] + m[2] + m[3]
+	}
+
+	matches, err := filepath.Glob(dir + include)
+	if err != nil {
+		return nil, fmt.Errorf("error globbing %v: %w", pattern, err)
+	}
+	var paths []string
+	for _, m := range matches {
+		if exclude != "" {
+			excluded, err := filepath.Match(exclude, filepath.Base(m))
+			if err != nil {
+				return nil, fmt.Errorf("error matching %v: %w", pattern, err)
+			}
+			if excluded {
+				continue
+			}
+		}
+		paths = append(paths, m)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// CheckPermissions verifies that every file matched by pattern has the given
+// permission, or one of DefaultPermissions when permission is empty. A pattern
+// that matches nothing fails, as the script did.
+func CheckPermissions(root, pattern, permission string) (*Result, error) {
+	expected := DefaultPermissions
+	if permission != "" {
+		expected = []string{permission}
+	}
+	paths, err := Expand(root, pattern)
+	if err != nil {
+		return nil, err
+	}
+	r := &Result{Passed: len(paths) > 0, Expected: expected}
+	for _, p := range paths {
+		info, err := Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		r.add(info, contains(expected, info.Mode))
+	}
+	return r, nil
+}
+
+// CheckOwnerInDir verifies that dir and every file in it are owned by
+// root:root. Files named kube-etcd-* may also be owned by etcd:etcd.
+func CheckOwnerInDir(root, dir string) (*Result, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("no directory specified")
+	}
+	dir = helpers.HostPath(root, dir)
+	dirInfo, err := Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	r := &Result{Passed: true, Expected: []string{RootOwner}}
+	r.add(dirInfo, dirInfo.Owner == RootOwner)
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		return nil, fmt.Errorf("error globbing %v: %w", dir, err)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		info, err := Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		allowed := []string{RootOwner}
+		if strings.HasPrefix(strings.TrimSuffix(filepath.Base(p), ".pem"), etcdFilePrefix) {
+			allowed = append(allowed, EtcdOwner)
+		}
+		r.add(info, contains(allowed, info.Owner))
+	}
+	return r, nil
+}
+
+// KubeletCAFile returns the --client-ca-file of the running kubelet, prefixed
+// with root. fallback is used when no kubelet sets the flag.
+func KubeletCAFile(root, procDir, fallback string) (string, error) {
+	args, err := helpers.FindProcess(procDir, func(args []string) bool {
+		cmdline := strings.Join(args, " ")
+		if !strings.Contains(cmdline, "kubelet") || strings.Contains(cmdline, "apiserver") {
+			return false
+		}
+		_, ok := helpers.FlagValue(args, "client-ca-file")
+		return ok
+	})
+	if err != nil {
+		return "", err
+	}
+	if caFile, _ := helpers.FlagValue(args, "client-ca-file"); caFile != "" {
+		return helpers.HostPath(root, caFile), nil
+	}
+	if fallback == "" {
+		return "", fmt.Errorf("unable to find the kubelet --client-ca-file")
+	}
+	return fallback, nil
+}
+
+func (r *Result) add(info *Info, ok bool) {
+	r.Files = append(r.Files, info)
+	if !ok {
+		r.Passed = false
+		r.Offending = append(r.Offending, info)
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+//go:build !windows
+
+package files
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestFiles(t *testing.T, modes map[string]os.FileMode) string {
+	dir := t.TempDir()
+	for name, mode := range modes {
+		p := filepath.Join(dir, name)
+		require.Nil(t, os.WriteFile(p, []byte(name), mode), "error writing test file")
+		require.Nil(t, os.Chmod(p, mode), "error setting test file mode")
+	}
+	return dir
+}
+
+func TestExpand(t *testing.T) {
+	dir := writeTestFiles(t, map[string]os.FileMode{
+		"kube-ca.pem":            0o644,
+		"kube-ca-key.pem":        0o600,
+		"kube-apiserver.pem":     0o644,
+		"kube-apiserver-key.pem": 0o600,
+		"README":                 0o644,
+	})
+
+	tests := []struct {
+		name     string
+		root     string
+		pattern  string
+		expected []string
+	}{
+		{
+			name:     "directory",
+			pattern:  dir,
+			expected: []string{"README", "kube-apiserver-key.pem", "kube-apiserver.pem", "kube-ca-key.pem", "kube-ca.pem"},
+		},
+		{
+			name:     "glob",
+			pattern:  filepath.Join(dir, "*key.pem"),
+			expected: []string{"kube-apiserver-key.pem", "kube-ca-key.pem"},
+		},
+		{
+			name:     "negated glob",
+			pattern:  filepath.Join(dir, "!(*key).pem"),
+			expected: []string{"kube-apiserver.pem", "kube-ca.pem"},
+		},
+		{
+			name:     "root prefix",
+			root:     filepath.Dir(dir),
+			pattern:  filepath.Join("/", filepath.Base(dir), "kube-ca.pem"),
+			expected: []string{"kube-ca.pem"},
+		},
+		{
+			name:    "no match",
+			pattern: filepath.Join(dir, "*.crt"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paths, err := Expand(tt.root, tt.pattern)
+			require.Nil(t, err)
+			var names []string
+			for _, p := range paths {
+				names = append(names, filepath.Base(p))
+			}
+			assert.Equal(t, tt.expected, names)
+		})
+	}
+}
+
+func TestCheckPermissions(t *testing.T) {
+	dir := writeTestFiles(t, map[string]os.FileMode{
+		"kube-ca.pem":     0o644,
+		"kube-ca-key.pem": 0o600,
+		"kube-node.pem":   0o664,
+	})
+
+	r, err := CheckPermissions("", filepath.Join(dir, "*key.pem"), "600")
+	require.Nil(t, err)
+	assert.True(t, r.Passed)
+	assert.Equal(t, "true", r.Text())
+
+	r, err = CheckPermissions("", filepath.Join(dir, "!(*key).pem"), "")
+	require.Nil(t, err)
+	assert.False(t, r.Passed)
+	assert.Equal(t, "false", r.Text())
+	require.Len(t, r.Offending, 1)
+	assert.Equal(t, filepath.Join(dir, "kube-node.pem"), r.Offending[0].Path)
+	assert.Equal(t, "664", r.Offending[0].Mode)
+
+	r, err = CheckPermissions("", filepath.Join(dir, "*.crt"), "")
+	require.Nil(t, err)
+	assert.False(t, r.Passed, "a pattern matching no files must fail")
+}
+
+func TestCheckOwnerInDir(t *testing.T) {
+	dir := writeTestFiles(t, map[string]os.FileMode{
+		"kube-etcd-node1.pem": 0o644,
+		"kube-ca.pem":         0o644,
+	})
+
+	r, err := CheckOwnerInDir("", dir)
+	require.Nil(t, err)
+	require.Len(t, r.Files, 3)
+	assert.Equal(t, dir, r.Files[0].Path, "the directory itself must be checked first")
+	for _, f := range r.Files {
+		assert.NotEmpty(t, f.Owner)
+	}
+	assert.Equal(t, len(r.Offending) == 0, r.Passed)
+
+	_, err = CheckOwnerInDir("", filepath.Join(dir, "missing"))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,146 @@
+// Package helpers holds the building blocks shared by the audit helpers that
+// kube-bench controls invoke through "kb-summarizer helper <name>".
+package helpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	OutputText = "text"
+	OutputJSON = "json"
+
+	DefaultProcDirectory = "/proc"
+)
+
+// Result is implemented by the outcome of every helper.
+type Result interface {
+	// Text returns the output the control files compare against. It must stay
+	// compatible with the shell scripts the helper replaced.
+	Text() string
+}
+
+// Print writes r to w in the requested output format.
+func Print(w io.Writer, format string, r Result) error {
+	switch format {
+	case OutputText, "":
+		_, err := fmt.Fprintln(w, r.Text())
+		return err
+	case OutputJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", " ")
+		return encoder.Encode(r)
+	}
+	return fmt.Errorf("unknown output format %q", format)
+}
+
+// BoolText renders a pass/fail outcome the way the legacy scripts did.
+func BoolText(b bool) string {
+	return strconv.FormatBool(b)
+}
+
+// HostPath returns path p as seen from inside a container that mounts the
+// host filesystem at root (e.g. /node). An empty root leaves p untouched.
+func HostPath(root, p string) string {
+	if root == "" || p == "" {
+		return p
+	}
+	return filepath.Join(root, p)
+}
+
+// FindProcess returns the command line of the first process under procDir
+// for which match returns true.
+func FindProcess(procDir string, match func(args []string) bool) ([]string, error) {
+	if procDir == "" {
+		procDir = DefaultProcDirectory
+	}
+	entries, err := os.ReadDir(procDir)
+	if err != nil {
+		return nil, fmt.Errorf("error listing %v: %w", procDir, err)
+	}
+	for _, e := range entries {
+		if _, err := strconv.Atoi(e.Name()); err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(procDir, e.Name(), "cmdline"))
+		if err != nil || len(data) == 0 {
+			// processes can exit while we walk /proc
+			continue
+		}
+		args := strings.Split(strings.TrimRight(string(data), "\x00"), "\x00")
+		if match(args) {
+			return args, nil
+		}
+	}
+	return nil, nil
+}
+
+// FlagValue returns the value of flag name in args, accepting both the
+// --name=value and --name value forms. The last occurrence wins, as it does
+// for the Kubernetes components.
+func FlagValue(args []string, name string) (string, bool) {
+	name = strings.TrimLeft(name, "-")
+	value, found := "", false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		k, v, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if k != name {
+			continue
+		}
+		found = true
+		switch {
+		case hasValue:
+			value = v
+		case i+1 < len(args) && !strings.HasPrefix(args[i+1], "-"):
+			value = args[i+1]
+			i++
+		default:
+			value = "true"
+		}
+	}
+	return value, found
+}
+
+// ParseFlags returns every flag of a command line, keyed by name without the
+// leading dashes. The last occurrence of a flag wins, except for the merged
+// flags whose occurrences are joined with commas, like --feature-gates.
+func ParseFlags(args []string, merged ...string) map[string]string {
+	flags := map[string]string{}
+	for i := 0; i < len(args); i++ {
+		if !strings.HasPrefix(args[i], "-") {
+			continue
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(args[i], "-"), "=")
+		if !hasValue {
+			if i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+				value = args[i+1]
+				i++
+			} else {
+				value = "true"
+			}
+		}
+		if previous := flags[name]; previous != "" && contains(merged, name) {
+			value = previous + "," + value
+		}
+		flags[name] = value
+	}
+	return flags
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
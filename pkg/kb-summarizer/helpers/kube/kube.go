@@ -0,0 +1,38 @@
+// Package kube builds the Kubernetes client used by the cluster scoped helpers.
+package kube
+
+import (
+	"fmt"
+	"path"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// NewClient returns a clientset for kubeconfig. When kubeconfig is empty the
+// KUBECONFIG environment variable is honoured, falling back to the in-cluster
+// configuration of the scan pod.
+func NewClient(kubeconfig string) (kubernetes.Interface, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = kubeconfig
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("error building kubernetes client config: %w", err)
+	}
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("error creating kubernetes client: %w", err)
+	}
+	return client, nil
+}
+
+// MatchAny reports whether name matches any of the shell patterns, e.g.
+// "cattle-*". Invalid patterns never match.
+func MatchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, err := path.Match(p, name); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Workload is a pod, or the pod template of a controller.
+type Workload struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Spec      *corev1.PodSpec
+}
+
+func (w *Workload) String() string {
+	return w.Kind + "/" + w.Namespace + "/" + w.Name
+}
+
+// ListWorkloads returns the pods and pod templates of the cluster. Pods and
+// templates managed by a controller are left out, as their owner is listed
+// instead.
+func ListWorkloads(ctx context.Context, client kubernetes.Interface) ([]*Workload, error) {
+	var workloads []*Workload
+	add := func(kind string, meta *metav1.ObjectMeta, spec *corev1.PodSpec) {
+		if metav1.GetControllerOf(meta) != nil {
+			return
+		}
+		workloads = append(workloads, &Workload{Kind: kind, Namespace: meta.Namespace, Name: meta.Name, Spec: spec})
+	}
+	all := metav1.NamespaceAll
+	opts := metav1.ListOptions{}
+
+	pods, err := client.CoreV1().Pods(all).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("error listing pods: %w", err)
+	}
+	for i := range pods.Items {
+		add("Pod", &pods.Items[i].ObjectMeta, &pods.Items[i].Spec)
+	}
+	deployments, err := client.AppsV1().Deployments(all).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("error listing deployments: %w", err)
+	}
+	for i := range deployments.Items {
+		add("Deployment", &deployments.Items[i].ObjectMeta, &deployments.Items[i].Spec.Template.Spec)
+	}
+	replicaSets, err := client.AppsV1().ReplicaSets(all).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("error listing replica sets: %w", err)
+	}
+	for i := range replicaSets.Items {
+		add("ReplicaSet", &replicaSets.Items[i].ObjectMeta, &replicaSets.Items[i].Spec.Template.Spec)
+	}
+	daemonSets, err := client.AppsV1().DaemonSets(all).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("error listing daemon sets: %w", err)
+	}
+	for i := range daemonSets.Items {
+		add("DaemonSet", &daemonSets.Items[i].ObjectMeta, &daemonSets.Items[i].Spec.Template.Spec)
+	}
+	statefulSets, err := client.AppsV1().StatefulSets(all).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("error listing stateful sets: %w", err)
+	}
+	for i := range statefulSets.Items {
+		add("StatefulSet", &statefulSets.Items[i].ObjectMeta, &statefulSets.Items[i].Spec.Template.Spec)
+	}
+	jobs, err := client.BatchV1().Jobs(all).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("error listing jobs: %w", err)
+	}
+	for i := range jobs.Items {
+		add("Job", &jobs.Items[i].ObjectMeta, &jobs.Items[i].Spec.Template.Spec)
+	}
+	cronJobs, err := client.BatchV1().CronJobs(all).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("error listing cron jobs: %w", err)
+	}
+	for i := range cronJobs.Items {
+		add("CronJob", &cronJobs.Items[i].ObjectMeta, &cronJobs.Items[i].Spec.JobTemplate.Spec.Template.Spec)
+	}
+	return workloads, nil
+}
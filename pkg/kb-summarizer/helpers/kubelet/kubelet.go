@@ -0,0 +1,347 @@
+// Package kubelet works out the effective configuration of the kubelet from
+// its configuration file, its drop-in directory and its command line, so the
+// CIS 4.2.x checks compare against what the kubelet actually runs with.
+package kubelet
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rancher/security-scan/pkg/kb-summarizer/helpers"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// DefaultConfigFile is read when the kubelet runs without --config.
+	DefaultConfigFile = "/var/lib/kubelet/config.yaml"
+
+	// SourceFlag marks the settings coming from the command line.
+	SourceFlag = "flag"
+
+	dropInSuffix = ".conf"
+)
+
+type kind int
+
+const (
+	kindString kind = iota
+	kindBool
+	kindInt
+	kindList
+	kindBoolMap
+)
+
+type field struct {
+	path string
+	kind kind
+}
+
+// flagFields maps the kubelet flags to the KubeletConfiguration fields they
+// override. Flags without a field, like --hostname-override, are kept under
+// their own name.
+var flagFields = map[string]field{
+	"address":                                {"address", kindString},
+	"anonymous-auth":                         {"authentication.anonymous.enabled", kindBool},
+	"authentication-token-webhook":           {"authentication.webhook.enabled", kindBool},
+	"authentication-token-webhook-cache-ttl": {"authentication.webhook.cacheTTL", kindString},
+	"authorization-mode":                     {"authorization.mode", kindString},
+	"cgroup-driver":                          {"cgroupDriver", kindString},
+	"client-ca-file":                         {"authentication.x509.clientCAFile", kindString},
+	"cluster-dns":                            {"clusterDNS", kindList},
+	"cluster-domain":                         {"clusterDomain", kindString},
+	"container-runtime-endpoint":             {"containerRuntimeEndpoint", kindString},
+	"event-burst":                            {"eventBurst", kindInt},
+	"event-qps":                              {"eventRecordQPS", kindInt},
+	"fail-swap-on":                           {"failSwapOn", kindBool},
+	"feature-gates":                          {"featureGates", kindBoolMap},
+	"healthz-bind-address":                   {"healthzBindAddress", kindString},
+	"healthz-port":                           {"healthzPort", kindInt},
+	"make-iptables-util-chains":              {"makeIPTablesUtilChains", kindBool},
+	"max-pods":                               {"maxPods", kindInt},
+	"pod-manifest-path":                      {"staticPodPath", kindString},
+	"pod-max-pids":                           {"podPidsLimit", kindInt},
+	"port":                                   {"port", kindInt},
+	"protect-kernel-defaults":                {"protectKernelDefaults", kindBool},
+	"read-only-port":                         {"readOnlyPort", kindInt},
+	"resolv-conf":                            {"resolvConf", kindString},
+	"rotate-certificates":                    {"rotateCertificates", kindBool},
+	"rotate-server-certificates":             {"serverTLSBootstrap", kindBool},
+	"seccomp-default":                        {"seccompDefault", kindBool},
+	"streaming-connection-idle-timeout":      {"streamingConnectionIdleTimeout", kindString},
+	"tls-cert-file":                          {"tlsCertFile", kindString},
+	"tls-cipher-suites":                      {"tlsCipherSuites", kindList},
+	"tls-min-version":                        {"tlsMinVersion", kindString},
+	"tls-private-key-file":                   {"tlsPrivateKeyFile", kindString},
+}
+
+// Options configure Resolve.
+type Options struct {
+	// Root is where the host filesystem is mounted.
+	Root string
+	// ProcDir is searched for the kubelet process when Args is empty.
+	ProcDir string
+	// Args is the kubelet command line, without the binary. It replaces the
+	// process lookup, e.g. for k3s, which embeds the kubelet.
+	Args []string
+	// ConfigFile is read when the command line has no --config.
+	ConfigFile string
+}
+
+// Result is the effective kubelet configuration.
+type Result struct {
+	Args       []string `json:"args"`
+	ConfigFile string   `json:"configFile,omitempty"`
+	DropIns    []string `json:"dropIns,omitempty"`
+	// Config is the merged KubeletConfiguration, flags included.
+	Config map[string]any `json:"config"`
+	// Flags holds the flags that have no configuration field.
+	Flags map[string]string `json:"flags"`
+	// Sources records where each setting of Settings comes from: a file, or
+	// SourceFlag.
+	Sources map[string]string `json:"sources"`
+}
+
+// Settings returns every setting flattened to a dotted key, e.g.
+// authentication.anonymous.enabled, lists being joined with commas.
+func (r *Result) Settings() map[string]string {
+	settings := map[string]string{}
+	flatten("", r.Config, settings)
+	for k, v := range r.Flags {
+		settings[k] = v
+	}
+	return settings
+}
+
+// Text renders one sorted key=value line per setting, which the flag tests of
+// the control files match on.
+func (r *Result) Text() string {
+	settings := r.Settings()
+	keys := make([]string, 0, len(settings))
+	for k := range settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	lines := make([]string, 0, len(keys))
+	for _, k := range keys {
+		lines = append(lines, k+"="+settings[k])
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Resolve merges the configuration the way the kubelet does: the
+// configuration file first, then the drop-in files of --config-dir in lexical
+// order, then the command line flags. Without Args, it fails when no kubelet
+// process runs, rather than reporting the defaults of a kubelet that may not
+// exist.
+func Resolve(opts Options) (*Result, error) {
+	args := opts.Args
+	if len(args) == 0 {
+		cmdline, err := helpers.FindProcess(opts.ProcDir, isKubelet)
+		if err != nil {
+			return nil, err
+		}
+		if cmdline == nil {
+			return nil, fmt.Errorf("no kubelet process found, pass its command line with --args")
+		}
+		args = trimBinary(cmdline)
+	}
+	r := &Result{Args: args, Config: map[string]any{}, Flags: map[string]string{}, Sources: map[string]string{}}
+	flags := ParseFlags(args)
+
+	configFile, explicit := flags["config"]
+	if !explicit {
+		configFile = opts.ConfigFile
+	}
+	if configFile != "" {
+		config, err := load(helpers.HostPath(opts.Root, configFile))
+		switch {
+		case err == nil:
+			r.ConfigFile = configFile
+			r.merge(config, configFile)
+		case explicit || !os.IsNotExist(err):
+			return nil, err
+		}
+	}
+
+	if dir, ok := flags["config-dir"]; ok && dir != "" {
+		entries, err := os.ReadDir(helpers.HostPath(opts.Root, dir))
+		if err != nil {
+			return nil, fmt.Errorf("error reading kubelet drop-in directory: %w", err)
+		}
+		for _, e := range entries {
+			if e.IsDir() || !strings.HasSuffix(e.Name(), dropInSuffix) {
+				continue
+			}
+			dropIn := path.Join(dir, e.Name())
+			config, err := load(helpers.HostPath(opts.Root, dropIn))
+			if err != nil {
+				return nil, err
+			}
+			r.DropIns = append(r.DropIns, dropIn)
+			r.merge(config, dropIn)
+		}
+	}
+
+	for name, value := range flags {
+		if name == "config" || name == "config-dir" {
+			continue
+		}
+		f, ok := flagFields[name]
+		if !ok {
+			r.Flags[name] = value
+			r.Sources[name] = SourceFlag
+			continue
+		}
+		v, err := convert(f.kind, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q for kubelet flag --%v: %w", value, name, err)
+		}
+		patch := map[string]any{}
+		set(patch, strings.Split(f.path, "."), v)
+		r.merge(patch, SourceFlag)
+	}
+	return r, nil
+}
+
+// ParseFlags returns the flags of a kubelet command line. The last
+// occurrence of a flag wins, except for --feature-gates whose occurrences
+// are merged.
+func ParseFlags(args []string) map[string]string {
+	return helpers.ParseFlags(args, "feature-gates")
+}
+
+func isKubelet(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	base := filepath.Base(args[0])
+	return base == "kubelet" || (base == "hyperkube" && len(args) > 1 && args[1] == "kubelet")
+}
+
+func trimBinary(cmdline []string) []string {
+	if len(cmdline) == 0 {
+		return nil
+	}
+	if filepath.Base(cmdline[0]) == "hyperkube" {
+		return cmdline[2:]
+	}
+	return cmdline[1:]
+}
+
+// load reads a KubeletConfiguration file, YAML or JSON.
+func load(file string) (map[string]any, error) {
+	data, err := os.ReadFile(filepath.Clean(file))
+	if err != nil {
+		return nil, err
+	}
+	config := map[string]any{}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("error parsing kubelet configuration %v: %w", file, err)
+	}
+	delete(config, "apiVersion")
+	delete(config, "kind")
+	return config, nil
+}
+
+// merge applies patch over the configuration: maps are merged recursively,
+// any other value is replaced.
+func (r *Result) merge(patch map[string]any, source string) {
+	mergeInto(r.Config, patch, "", source, r.Sources)
+}
+
+func mergeInto(dst, patch map[string]any, prefix, source string, sources map[string]string) {
+	for k, v := range patch {
+		key := prefix + k
+		if m, ok := v.(map[string]any); ok {
+			existing, ok := dst[k].(map[string]any)
+			if !ok {
+				existing = map[string]any{}
+				dst[k] = existing
+			}
+			mergeInto(existing, m, key+".", source, sources)
+			continue
+		}
+		dst[k] = v
+		sources[key] = source
+	}
+}
+
+func set(m map[string]any, path []string, v any) {
+	for _, p := range path[:len(path)-1] {
+		next := map[string]any{}
+		m[p] = next
+		m = next
+	}
+	m[path[len(path)-1]] = v
+}
+
+func convert(k kind, value string) (any, error) {
+	switch k {
+	case kindBool:
+		return strconv.ParseBool(value)
+	case kindInt:
+		return strconv.Atoi(value)
+	case kindList:
+		var list []any
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				list = append(list, v)
+			}
+		}
+		return list, nil
+	case kindBoolMap:
+		m := map[string]any{}
+		for _, kv := range strings.Split(value, ",") {
+			if kv = strings.TrimSpace(kv); kv == "" {
+				continue
+			}
+			name, v, ok := strings.Cut(kv, "=")
+			if !ok {
+				return nil, fmt.Errorf("missing value for %v", name)
+			}
+			b, err := strconv.ParseBool(strings.TrimSpace(v))
+			if err != nil {
+				return nil, err
+			}
+			m[strings.TrimSpace(name)] = b
+		}
+		return m, nil
+	}
+	return value, nil
+}
+
+func flatten(prefix string, v any, out map[string]string) {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, child := range v {
+			flatten(prefix+k+".", child, out)
+		}
+	case []any:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			values = append(values, scalar(item))
+		}
+		out[strings.TrimSuffix(prefix, ".")] = strings.Join(values, ",")
+	default:
+		out[strings.TrimSuffix(prefix, ".")] = scalar(v)
+	}
+}
+
+func scalar(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	case map[string]any, []any:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,109 @@
+package kubelet
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.Nil(t, os.MkdirAll(filepath.Dir(path), 0o750))
+	require.Nil(t, os.WriteFile(path, []byte(content), 0o600))
+}
+
+func TestResolve(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "etc/kubelet/config.yaml"), `apiVersion: kubelet.config.k8s.io/v1beta1
+kind: KubeletConfiguration
+authentication:
+  anonymous:
+    enabled: true
+  x509:
+    clientCAFile: /etc/kubelet/ca.crt
+readOnlyPort: 10255
+featureGates:
+  RotateKubeletServerCertificate: false
+tlsCipherSuites:
+- TLS_RSA_WITH_AES_128_CBC_SHA
+`)
+	writeFile(t, filepath.Join(root, "etc/kubelet/conf.d/10-hardening.conf"), `{"authentication":{"anonymous":{"enabled":false}},"readOnlyPort":0}`)
+	writeFile(t, filepath.Join(root, "etc/kubelet/conf.d/20-ciphers.conf"), `tlsCipherSuites:
+- TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256
+- TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384
+`)
+	writeFile(t, filepath.Join(root, "etc/kubelet/conf.d/README"), "not a drop-in")
+
+	args := []string{
+		"--config=/etc/kubelet/config.yaml",
+		"--config-dir", "/etc/kubelet/conf.d",
+		"--read-only-port=10255",
+		"--feature-gates=RotateKubeletServerCertificate=true",
+		"--feature-gates", "SomeAlpha=false",
+		"--hostname-override=node-1",
+		"--protect-kernel-defaults",
+	}
+	r, err := Resolve(Options{Root: root, Args: args})
+	require.Nil(t, err)
+
+	assert.Equal(t, "/etc/kubelet/config.yaml", r.ConfigFile)
+	assert.Equal(t, []string{"/etc/kubelet/conf.d/10-hardening.conf", "/etc/kubelet/conf.d/20-ciphers.conf"}, r.DropIns)
+	assert.Equal(t, map[string]string{
+		"authentication.anonymous.enabled":            "false",
+		"authentication.x509.clientCAFile":            "/etc/kubelet/ca.crt",
+		"featureGates.RotateKubeletServerCertificate": "true",
+		"featureGates.SomeAlpha":                      "false",
+		"hostname-override":                           "node-1",
+		"protectKernelDefaults":                       "true",
+		"readOnlyPort":                                "10255",
+		"tlsCipherSuites":                             "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384",
+	}, r.Settings())
+	assert.Equal(t, "/etc/kubelet/conf.d/10-hardening.conf", r.Sources["authentication.anonymous.enabled"])
+	assert.Equal(t, SourceFlag, r.Sources["readOnlyPort"])
+	assert.Equal(t, "/etc/kubelet/config.yaml", r.Sources["authentication.x509.clientCAFile"])
+
+	assert.Equal(t, "authentication.anonymous.enabled=false\n"+
+		"authentication.x509.clientCAFile=/etc/kubelet/ca.crt\n"+
+		"featureGates.RotateKubeletServerCertificate=true\n"+
+		"featureGates.SomeAlpha=false\n"+
+		"hostname-override=node-1\n"+
+		"protectKernelDefaults=true\n"+
+		"readOnlyPort=10255\n"+
+		"tlsCipherSuites=TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384", r.Text())
+}
+
+func TestResolveConfigFile(t *testing.T) {
+	root := t.TempDir()
+
+	// the fallback configuration file is optional
+	r, err := Resolve(Options{Root: root, Args: []string{"--anonymous-auth=false"}, ConfigFile: DefaultConfigFile})
+	require.Nil(t, err)
+	assert.Empty(t, r.ConfigFile)
+	assert.Equal(t, map[string]string{"authentication.anonymous.enabled": "false"}, r.Settings())
+
+	// the one given on the command line is not
+	_, err = Resolve(Options{Root: root, Args: []string{"--config=/missing.yaml"}})
+	assert.NotNil(t, err)
+
+	_, err = Resolve(Options{Root: root, Args: []string{"--read-only-port=none"}})
+	assert.NotNil(t, err)
+}
+
+func TestResolveProcess(t *testing.T) {
+	procDir := t.TempDir()
+	writeFile(t, filepath.Join(procDir, "1", "cmdline"), "/sbin/init\x00")
+	writeFile(t, filepath.Join(procDir, "42", "cmdline"), "/usr/local/bin/hyperkube\x00kubelet\x00--event-qps=5\x00")
+
+	r, err := Resolve(Options{ProcDir: procDir})
+	require.Nil(t, err)
+	assert.Equal(t, []string{"--event-qps=5"}, r.Args)
+	assert.Equal(t, map[string]string{"eventRecordQPS": "5"}, r.Settings())
+
+	procDir = t.TempDir()
+	writeFile(t, filepath.Join(procDir, "1", "cmdline"), "/sbin/init\x00")
+	_, err = Resolve(Options{ProcDir: procDir})
+	assert.ErrorContains(t, err, "no kubelet process found")
+}
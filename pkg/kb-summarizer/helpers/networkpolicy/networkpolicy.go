@@ -0,0 +1,149 @@
+// Package networkpolicy checks that namespaces are covered by at least one
+// NetworkPolicy. It replaces check_for_network_policies.sh and its K3s and
+// RKE2 variants.
+package networkpolicy
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rancher/security-scan/pkg/kb-summarizer/helpers"
+	"github.com/rancher/security-scan/pkg/kb-summarizer/helpers/kube"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	DistroGeneric = "generic"
+	DistroRKE2    = "rke2"
+	DistroK3s     = "k3s"
+
+	DefaultCNIDirectory = "/etc/cni/net.d"
+)
+
+// distroExclusions are namespaces a distribution never creates network
+// policies for, as they do not run workloads.
+var distroExclusions = map[string][]string{
+	DistroGeneric: {},
+	DistroRKE2:    {"kube-node-lease"},
+	DistroK3s:     {"kube-node-lease"},
+}
+
+// policyCNIs are the name fragments of CNI configs that enforce network policies.
+var policyCNIs = []string{"calico", "canal", "cilium"}
+
+// Options select the namespaces to check.
+type Options struct {
+	Distro string
+	// Namespaces restricts the check to these namespaces, all when empty.
+	Namespaces []string
+	// Exclude holds namespace patterns that are not checked, on top of the
+	// distribution defaults.
+	Exclude []string
+}
+
+// Result is the outcome of the coverage check.
+type Result struct {
+	Passed    bool     `json:"passed"`
+	Distro    string   `json:"distro"`
+	Covered   []string `json:"covered"`
+	Uncovered []string `json:"uncovered"`
+	Excluded  []string `json:"excluded,omitempty"`
+}
+
+func (r *Result) Text() string {
+	return helpers.BoolText(r.Passed)
+}
+
+// CNIResult reports whether a network policy capable CNI is configured.
+type CNIResult struct {
+	Passed  bool     `json:"passed"`
+	Configs []string `json:"configs"`
+}
+
+func (r *CNIResult) Text() string {
+	return helpers.BoolText(r.Passed)
+}
+
+// Check lists namespaces and network policies once and reports the
+// namespaces without any policy.
+func Check(ctx context.Context, client kubernetes.Interface, opts Options) (*Result, error) {
+	distro := opts.Distro
+	if distro == "" {
+		distro = DistroGeneric
+	}
+	defaults, ok := distroExclusions[distro]
+	if !ok {
+		return nil, fmt.Errorf("unknown distro %q", distro)
+	}
+	exclude := append(append([]string{}, defaults...), opts.Exclude...)
+
+	namespaces := opts.Namespaces
+	if len(namespaces) == 0 {
+		nsList, err := client.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("error listing namespaces: %w", err)
+		}
+		for _, ns := range nsList.Items {
+			if ns.DeletionTimestamp != nil {
+				continue
+			}
+			namespaces = append(namespaces, ns.Name)
+		}
+	}
+
+	policies, err := client.NetworkingV1().NetworkPolicies(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error listing network policies: %w", err)
+	}
+	policyCount := map[string]int{}
+	for _, p := range policies.Items {
+		policyCount[p.Namespace]++
+	}
+
+	r := &Result{Distro: distro, Covered: []string{}, Uncovered: []string{}}
+	sort.Strings(namespaces)
+	for _, ns := range namespaces {
+		switch {
+		case kube.MatchAny(exclude, ns):
+			r.Excluded = append(r.Excluded, ns)
+		case policyCount[ns] > 0:
+			r.Covered = append(r.Covered, ns)
+		default:
+			r.Uncovered = append(r.Uncovered, ns)
+		}
+	}
+	r.Passed = len(r.Uncovered) == 0
+	return r, nil
+}
+
+// CheckCNI looks for the config of a CNI that enforces network policies in
+// cniDir, which is resolved below root.
+func CheckCNI(root, cniDir string) (*CNIResult, error) {
+	if cniDir == "" {
+		cniDir = DefaultCNIDirectory
+	}
+	cniDir = helpers.HostPath(root, cniDir)
+	r := &CNIResult{Configs: []string{}}
+	err := filepath.WalkDir(cniDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		for _, cni := range policyCNIs {
+			if strings.Contains(d.Name(), cni) {
+				r.Configs = append(r.Configs, path)
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error walking %v: %w", cniDir, err)
+	}
+	r.Passed = len(r.Configs) > 0
+	return r, nil
+}
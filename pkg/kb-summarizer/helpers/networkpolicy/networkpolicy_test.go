@@ -0,0 +1,103 @@
+package networkpolicy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func namespace(name string) runtime.Object {
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+}
+
+func policy(namespace string) runtime.Object {
+	return &networkingv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Name: "default-deny", Namespace: namespace}}
+}
+
+func TestCheck(t *testing.T) {
+	objects := []runtime.Object{
+		namespace("default"),
+		namespace("kube-system"),
+		namespace("kube-public"),
+		namespace("kube-node-lease"),
+		namespace("cattle-system"),
+		namespace("app"),
+		policy("default"),
+		policy("kube-system"),
+		policy("kube-public"),
+		policy("app"),
+	}
+
+	tests := []struct {
+		name      string
+		opts      Options
+		passed    bool
+		uncovered []string
+		excluded  []string
+	}{
+		{
+			name:      "generic checks every namespace",
+			opts:      Options{},
+			passed:    false,
+			uncovered: []string{"cattle-system", "kube-node-lease"},
+		},
+		{
+			name:      "rke2 excludes kube-node-lease",
+			opts:      Options{Distro: DistroRKE2},
+			passed:    false,
+			uncovered: []string{"cattle-system"},
+			excluded:  []string{"kube-node-lease"},
+		},
+		{
+			name:      "user exclusions",
+			opts:      Options{Distro: DistroK3s, Exclude: []string{"cattle-*"}},
+			passed:    true,
+			uncovered: []string{},
+			excluded:  []string{"cattle-system", "kube-node-lease"},
+		},
+		{
+			name:      "selected namespaces only",
+			opts:      Options{Distro: DistroRKE2, Namespaces: []string{"kube-system", "kube-public", "default"}},
+			passed:    true,
+			uncovered: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewClientset(objects...)
+			r, err := Check(context.Background(), client, tt.opts)
+			require.Nil(t, err)
+			assert.Equal(t, tt.passed, r.Passed)
+			assert.Equal(t, tt.uncovered, r.Uncovered)
+			assert.Equal(t, tt.excluded, r.Excluded)
+		})
+	}
+
+	_, err := Check(context.Background(), fake.NewClientset(), Options{Distro: "unknown"})
+	assert.NotNil(t, err)
+}
+
+func TestCheckCNI(t *testing.T) {
+	root := t.TempDir()
+	cniDir := filepath.Join(root, DefaultCNIDirectory)
+	require.Nil(t, os.MkdirAll(cniDir, 0750))
+
+	r, err := CheckCNI(root, "")
+	require.Nil(t, err)
+	assert.False(t, r.Passed)
+
+	require.Nil(t, os.WriteFile(filepath.Join(cniDir, "10-canal.conflist"), []byte("{}"), 0600))
+	r, err = CheckCNI(root, "")
+	require.Nil(t, err)
+	assert.True(t, r.Passed)
+	assert.Len(t, r.Configs, 1)
+}
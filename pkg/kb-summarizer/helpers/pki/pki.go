@@ -0,0 +1,394 @@
+// Package pki inspects the certificates and keys of the control plane PKI:
+// expiry windows, key algorithms and sizes, SANs of serving certificates and
+// the reuse of the apiserver CAs by etcd.
+package pki
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rancher/security-scan/pkg/kb-summarizer/helpers"
+)
+
+const (
+	CheckExpiry  = "expiry"
+	CheckKey     = "key"
+	CheckSAN     = "san"
+	CheckCAReuse = "ca-reuse"
+
+	FindingExpired     = "expired"
+	FindingExpiresSoon = "expires-soon"
+	FindingWeakKey     = "weak-key"
+	FindingMissingSAN  = "missing-san"
+	FindingWildcardSAN = "wildcard-san"
+	FindingCAReuse     = "ca-reuse"
+	FindingInvalid     = "invalid"
+
+	DefaultExpiryDays = 30
+
+	// minRSABits and minECDSABits are the smallest accepted key sizes.
+	minRSABits   = 2048
+	minECDSABits = 256
+)
+
+// Checks are all the checks, run when none is selected.
+var Checks = []string{CheckExpiry, CheckKey, CheckSAN, CheckCAReuse}
+
+// DefaultDirs are the PKI directories of RKE2, K3s and kubeadm. Missing
+// directories are skipped.
+var DefaultDirs = []string{
+	"/var/lib/rancher/rke2/server/tls",
+	"/var/lib/rancher/k3s/server/tls",
+	"/etc/kubernetes/pki",
+}
+
+// Options configure Inspect.
+type Options struct {
+	// Root is where the host filesystem is mounted.
+	Root string
+	Dirs []string
+	// Checks restricts the inspection to these checks.
+	Checks []string
+	// ExpiryDays is the window in which an expiring certificate is reported.
+	ExpiryDays int
+	// Now defaults to the current time.
+	Now time.Time
+}
+
+// Item is a certificate or a private key found in the PKI.
+type Item struct {
+	Path string `json:"path"`
+	// Type is either certificate or key.
+	Type          string    `json:"type"`
+	Subject       string    `json:"subject,omitempty"`
+	Issuer        string    `json:"issuer,omitempty"`
+	IsCA          bool      `json:"isCA,omitempty"`
+	Serving       bool      `json:"serving,omitempty"`
+	NotAfter      time.Time `json:"notAfter,omitempty"`
+	DaysRemaining int       `json:"daysRemaining,omitempty"`
+	Key           string    `json:"key,omitempty"`
+	DNSNames      []string  `json:"dnsNames,omitempty"`
+	IPs           []string  `json:"ipAddresses,omitempty"`
+	PublicKey     string    `json:"publicKeySHA256,omitempty"`
+	Findings      []string  `json:"findings"`
+	Details       []string  `json:"details,omitempty"`
+}
+
+// Result is the outcome of the inspection.
+type Result struct {
+	Passed     bool     `json:"passed"`
+	Dirs       []string `json:"dirs"`
+	Checks     []string `json:"checks"`
+	ExpiryDays int      `json:"expiryDays"`
+	Items      []*Item  `json:"items"`
+}
+
+// Text renders one line per certificate or key, to be tested with
+// use_multiple_values on is_compliant, or on days_remaining for a custom
+// expiry window. Only the certificate lines have days_remaining, the keys are
+// left out unless the key check is selected.
+func (r *Result) Text() string {
+	lines := make([]string, 0, len(r.Items))
+	for _, item := range r.Items {
+		findings := "none"
+		if len(item.Findings) > 0 {
+			findings = strings.Join(item.Findings, ",")
+		}
+		if item.Type == typeKey {
+			lines = append(lines, fmt.Sprintf("**key: %s algorithm: %s findings: %s is_compliant: %v",
+				item.Path, item.Key, findings, len(item.Findings) == 0))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("**cert: %s subject: %q algorithm: %s days_remaining: %d findings: %s is_compliant: %v",
+			item.Path, item.Subject, item.Key, item.DaysRemaining, findings, len(item.Findings) == 0))
+	}
+	return strings.Join(lines, "\n")
+}
+
+const (
+	typeCertificate = "certificate"
+	typeKey         = "key"
+)
+
+// Inspect scans the PKI directories for PEM encoded certificates and private
+// keys. Files holding neither are ignored.
+func Inspect(opts Options) (*Result, error) {
+	checks := opts.Checks
+	if len(checks) == 0 {
+		checks = Checks
+	}
+	for _, c := range checks {
+		if !contains(Checks, c) {
+			return nil, fmt.Errorf("unknown check %q, expected one of %v", c, Checks)
+		}
+	}
+	if opts.ExpiryDays == 0 {
+		opts.ExpiryDays = DefaultExpiryDays
+	}
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+	r := &Result{Dirs: []string{}, Checks: checks, ExpiryDays: opts.ExpiryDays, Items: []*Item{}}
+
+	for _, dir := range opts.Dirs {
+		hostDir := helpers.HostPath(opts.Root, dir)
+		if _, err := os.Stat(hostDir); os.IsNotExist(err) {
+			continue
+		}
+		r.Dirs = append(r.Dirs, dir)
+		err := filepath.WalkDir(hostDir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(hostDir, path)
+			if err != nil {
+				return err
+			}
+			items, err := load(path, filepath.ToSlash(filepath.Join(dir, rel)))
+			if err != nil {
+				return err
+			}
+			r.Items = append(r.Items, items...)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(r.Dirs) == 0 {
+		return nil, fmt.Errorf("none of the PKI directories %v exist", opts.Dirs)
+	}
+	if !contains(checks, CheckKey) {
+		r.Items = certificates(r.Items)
+	}
+
+	for _, item := range r.Items {
+		if item.Type == typeCertificate {
+			item.DaysRemaining = int(item.NotAfter.Sub(opts.Now).Hours() / 24)
+		}
+	}
+	if contains(checks, CheckExpiry) {
+		checkExpiry(r.Items, opts)
+	}
+	if contains(checks, CheckKey) {
+		checkKey(r.Items)
+	}
+	if contains(checks, CheckSAN) {
+		checkSAN(r.Items)
+	}
+	if contains(checks, CheckCAReuse) {
+		checkCAReuse(r.Items)
+	}
+
+	r.Passed = true
+	for _, item := range r.Items {
+		if len(item.Findings) > 0 {
+			r.Passed = false
+		}
+	}
+	return r, nil
+}
+
+// load returns the certificates and keys of a PEM file.
+func load(path, display string) ([]*Item, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	var items []*Item
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return items, nil
+		}
+		switch {
+		case block.Type == "CERTIFICATE":
+			items = append(items, certificate(display, block.Bytes))
+		case strings.HasSuffix(block.Type, "PRIVATE KEY"):
+			items = append(items, privateKey(display, block))
+		}
+	}
+}
+
+func certificate(path string, der []byte) *Item {
+	item := &Item{Path: path, Type: typeCertificate, Findings: []string{}}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		item.Findings = append(item.Findings, FindingInvalid)
+		item.Details = append(item.Details, err.Error())
+		return item
+	}
+	item.Subject = cert.Subject.CommonName
+	item.Issuer = cert.Issuer.CommonName
+	item.IsCA = cert.IsCA
+	item.NotAfter = cert.NotAfter
+	item.Key = keyDescription(cert.PublicKey)
+	item.DNSNames = cert.DNSNames
+	for _, ip := range cert.IPAddresses {
+		item.IPs = append(item.IPs, ip.String())
+	}
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	item.PublicKey = hex.EncodeToString(sum[:])
+	item.Serving = !cert.IsCA && hasServerAuth(cert)
+	return item
+}
+
+func privateKey(path string, block *pem.Block) *Item {
+	item := &Item{Path: path, Type: typeKey, Findings: []string{}}
+	var key any
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		// e.g. ENCRYPTED PRIVATE KEY, whose algorithm can't be read
+		item.Key = "unknown"
+		return item
+	}
+	if err != nil {
+		item.Findings = append(item.Findings, FindingInvalid)
+		item.Details = append(item.Details, err.Error())
+		return item
+	}
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		item.Key = keyDescription(&k.PublicKey)
+	case *ecdsa.PrivateKey:
+		item.Key = keyDescription(&k.PublicKey)
+	case ed25519.PrivateKey:
+		item.Key = keyDescription(k.Public())
+	}
+	return item
+}
+
+// certificates drops the keys of the items.
+func certificates(items []*Item) []*Item {
+	certs := []*Item{}
+	for _, item := range items {
+		if item.Type == typeCertificate {
+			certs = append(certs, item)
+		}
+	}
+	return certs
+}
+
+func keyDescription(key any) string {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA-%d", k.N.BitLen())
+	case *ecdsa.PublicKey:
+		return fmt.Sprintf("ECDSA-%d", k.Curve.Params().BitSize)
+	case ed25519.PublicKey:
+		return "Ed25519"
+	}
+	return "unknown"
+}
+
+func checkExpiry(items []*Item, opts Options) {
+	for _, item := range items {
+		if item.Type != typeCertificate || item.NotAfter.IsZero() {
+			continue
+		}
+		switch {
+		case !opts.Now.Before(item.NotAfter):
+			item.Findings = append(item.Findings, FindingExpired)
+		case item.DaysRemaining < opts.ExpiryDays:
+			item.Findings = append(item.Findings, FindingExpiresSoon)
+		}
+	}
+}
+
+func checkKey(items []*Item) {
+	for _, item := range items {
+		var bits int
+		algorithm, size, _ := strings.Cut(item.Key, "-")
+		if _, err := fmt.Sscan(size, &bits); err != nil {
+			continue
+		}
+		if (algorithm == "RSA" && bits < minRSABits) || (algorithm == "ECDSA" && bits < minECDSABits) {
+			item.Findings = append(item.Findings, FindingWeakKey)
+		}
+	}
+}
+
+func checkSAN(items []*Item) {
+	for _, item := range items {
+		if !item.Serving {
+			continue
+		}
+		if len(item.DNSNames) == 0 && len(item.IPs) == 0 {
+			item.Findings = append(item.Findings, FindingMissingSAN)
+		}
+		for _, name := range item.DNSNames {
+			if strings.HasPrefix(name, "*") {
+				item.Findings = append(item.Findings, FindingWildcardSAN)
+				break
+			}
+		}
+	}
+}
+
+// checkCAReuse reports the CAs of etcd, i.e. under an etcd directory, whose
+// key is also the key of a CA outside of it.
+func checkCAReuse(items []*Item) {
+	others := map[string][]string{}
+	for _, item := range items {
+		if item.IsCA && !isEtcd(item.Path) {
+			others[item.PublicKey] = append(others[item.PublicKey], item.Path)
+		}
+	}
+	for _, item := range items {
+		if !item.IsCA || !isEtcd(item.Path) {
+			continue
+		}
+		if shared, ok := others[item.PublicKey]; ok {
+			sort.Strings(shared)
+			item.Findings = append(item.Findings, FindingCAReuse)
+			item.Details = append(item.Details, "same key as "+strings.Join(shared, ","))
+		}
+	}
+}
+
+func isEtcd(path string) bool {
+	for _, element := range strings.Split(path, "/") {
+		if element == "etcd" {
+			return true
+		}
+	}
+	return false
+}
+
+func hasServerAuth(cert *x509.Certificate) bool {
+	for _, usage := range cert.ExtKeyUsage {
+		if usage == x509.ExtKeyUsageServerAuth {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
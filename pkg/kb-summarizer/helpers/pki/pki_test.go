@@ -0,0 +1,176 @@
+package pki
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	kb "github.com/aquasecurity/kube-bench/check"
+	"github.com/rancher/security-scan/pkg/kb-summarizer/eval"
+	"github.com/rancher/security-scan/pkg/kb-summarizer/summarizer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+var now = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+type certSpec struct {
+	cn       string
+	ca       bool
+	serving  bool
+	dnsNames []string
+	expires  time.Time
+	key      *ecdsa.PrivateKey
+}
+
+func writeCert(t *testing.T, path string, spec certSpec) {
+	t.Helper()
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: spec.cn},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              spec.expires,
+		IsCA:                  spec.ca,
+		BasicConstraintsValid: true,
+		DNSNames:              spec.dnsNames,
+	}
+	if spec.serving {
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	}
+	var signer crypto.Signer = spec.key
+	der, err := x509.CreateCertificate(rand.Reader, template, template, signer.Public(), signer)
+	require.Nil(t, err)
+	require.Nil(t, os.MkdirAll(filepath.Dir(path), 0o750))
+	require.Nil(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+}
+
+func writeKey(t *testing.T, path string, key *ecdsa.PrivateKey) {
+	t.Helper()
+	der, err := x509.MarshalECPrivateKey(key)
+	require.Nil(t, err)
+	require.Nil(t, os.MkdirAll(filepath.Dir(path), 0o750))
+	require.Nil(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0o600))
+}
+
+func newKey(t *testing.T, curve elliptic.Curve) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(curve, rand.Reader)
+	require.Nil(t, err)
+	return key
+}
+
+func TestInspect(t *testing.T) {
+	root := t.TempDir()
+	tls := filepath.Join(root, "var/lib/rancher/rke2/server/tls")
+	serverCA := newKey(t, elliptic.P256())
+	year := now.AddDate(1, 0, 0)
+
+	writeCert(t, filepath.Join(tls, "server-ca.crt"), certSpec{cn: "rke2-server-ca", ca: true, expires: now.AddDate(10, 0, 0), key: serverCA})
+	writeKey(t, filepath.Join(tls, "server-ca.key"), serverCA)
+	writeCert(t, filepath.Join(tls, "serving-kube-apiserver.crt"), certSpec{cn: "kube-apiserver", serving: true,
+		dnsNames: []string{"kubernetes", "kubernetes.default"}, expires: now.AddDate(0, 0, 10), key: newKey(t, elliptic.P256())})
+	writeCert(t, filepath.Join(tls, "client-admin.crt"), certSpec{cn: "system:admin", expires: now.AddDate(0, 0, -1), key: newKey(t, elliptic.P256())})
+	writeCert(t, filepath.Join(tls, "dynamic-cert.crt"), certSpec{cn: "dynamic", serving: true, expires: year, key: newKey(t, elliptic.P224())})
+	writeCert(t, filepath.Join(tls, "wildcard.crt"), certSpec{cn: "wildcard", serving: true, dnsNames: []string{"*.example.com"}, expires: year, key: newKey(t, elliptic.P256())})
+	writeCert(t, filepath.Join(tls, "etcd/server-ca.crt"), certSpec{cn: "etcd-server-ca", ca: true, expires: year, key: serverCA})
+	writeCert(t, filepath.Join(tls, "etcd/peer-ca.crt"), certSpec{cn: "etcd-peer-ca", ca: true, expires: year, key: newKey(t, elliptic.P256())})
+	require.Nil(t, os.WriteFile(filepath.Join(tls, "README"), []byte("not a certificate"), 0o600))
+
+	r, err := Inspect(Options{Root: root, Dirs: DefaultDirs, Now: now})
+	require.Nil(t, err)
+	assert.False(t, r.Passed)
+	assert.Equal(t, []string{"/var/lib/rancher/rke2/server/tls"}, r.Dirs)
+
+	findings := map[string][]string{}
+	for _, item := range r.Items {
+		findings[item.Path] = item.Findings
+	}
+	prefix := "/var/lib/rancher/rke2/server/tls/"
+	assert.Equal(t, map[string][]string{
+		prefix + "client-admin.crt":           {FindingExpired},
+		prefix + "dynamic-cert.crt":           {FindingWeakKey, FindingMissingSAN},
+		prefix + "etcd/peer-ca.crt":           {},
+		prefix + "etcd/server-ca.crt":         {FindingCAReuse},
+		prefix + "server-ca.crt":              {},
+		prefix + "server-ca.key":              {},
+		prefix + "serving-kube-apiserver.crt": {FindingExpiresSoon},
+		prefix + "wildcard.crt":               {FindingWildcardSAN},
+	}, findings)
+
+	r, err = Inspect(Options{Root: root, Dirs: DefaultDirs, Now: now, Checks: []string{CheckExpiry}, ExpiryDays: 5})
+	require.Nil(t, err)
+	for _, item := range r.Items {
+		if item.Path == prefix+"serving-kube-apiserver.crt" {
+			assert.Empty(t, item.Findings)
+			assert.Equal(t, 10, item.DaysRemaining)
+			assert.Equal(t, `**cert: `+prefix+`serving-kube-apiserver.crt subject: "kube-apiserver" algorithm: ECDSA-256 days_remaining: 10 findings: none is_compliant: true`,
+				(&Result{Items: []*Item{item}}).Text())
+		}
+	}
+
+	_, err = Inspect(Options{Root: root, Dirs: DefaultDirs, Checks: []string{"ocsp"}})
+	assert.NotNil(t, err)
+	_, err = Inspect(Options{Root: root, Dirs: []string{"/missing"}})
+	assert.NotNil(t, err)
+}
+
+// expiryCheck is a custom check failing when a certificate expires within 30
+// days.
+const expiryCheck = `
+id: 1.1.1
+text: "Ensure that no certificate expires within 30 days (Automated)"
+audit: kb-summarizer helper pki --check expiry
+use_multiple_values: true
+tests:
+  test_items:
+    - flag: "days_remaining"
+      compare:
+        op: gte
+        value: 30
+scored: true
+`
+
+func TestResult_Text_daysRemaining(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("kube-bench runs the audit commands with /bin/sh")
+	}
+	c := &kb.Check{}
+	require.Nil(t, yaml.Unmarshal([]byte(expiryCheck), c))
+	key := newKey(t, elliptic.P256())
+
+	for _, tt := range []struct {
+		days  int
+		state kb.State
+	}{
+		{days: 60, state: kb.PASS},
+		{days: 10, state: kb.FAIL},
+	} {
+		root := t.TempDir()
+		tls := filepath.Join(root, "var/lib/rancher/rke2/server/tls")
+		writeCert(t, filepath.Join(tls, "server-ca.crt"), certSpec{cn: "rke2-server-ca", ca: true, expires: now.AddDate(10, 0, 0), key: key})
+		writeKey(t, filepath.Join(tls, "server-ca.key"), key)
+		writeCert(t, filepath.Join(tls, "serving-kube-apiserver.crt"), certSpec{cn: "kube-apiserver", serving: true,
+			dnsNames: []string{"kubernetes"}, expires: now.AddDate(0, 0, tt.days), key: newKey(t, elliptic.P256())})
+
+		r, err := Inspect(Options{Root: root, Dirs: DefaultDirs, Now: now, Checks: []string{CheckExpiry}})
+		require.Nil(t, err)
+		assert.Len(t, r.Items, 2, "the keys are left out")
+		result, err := eval.Evaluate("custom", &summarizer.BenchmarkCheck{Check: c}, &eval.Outputs{Audit: r.Text()})
+		require.Nil(t, err)
+		assert.Equal(t, tt.state, result.State, "certificate expiring in %v days", tt.days)
+
+		r, err = Inspect(Options{Root: root, Dirs: DefaultDirs, Now: now, Checks: []string{CheckExpiry, CheckKey}})
+		require.Nil(t, err)
+		assert.Len(t, r.Items, 3, "the key check reports the keys")
+	}
+}
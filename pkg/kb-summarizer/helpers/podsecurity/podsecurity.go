@@ -0,0 +1,317 @@
+// Package podsecurity evaluates the running workloads of a cluster against
+// the baseline and restricted Pod Security Standards, giving the CIS 5.2.x
+// checks evidence beyond the presence of admission policies.
+package podsecurity
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rancher/security-scan/pkg/kb-summarizer/helpers/kube"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	LevelBaseline   = "baseline"
+	LevelRestricted = "restricted"
+
+	ControlPrivileged               = "privileged"
+	ControlHostPID                  = "host-pid"
+	ControlHostIPC                  = "host-ipc"
+	ControlHostNetwork              = "host-network"
+	ControlCapabilities             = "capabilities"
+	ControlSeccomp                  = "seccomp"
+	ControlAllowPrivilegeEscalation = "allow-privilege-escalation"
+	ControlRunAsNonRoot             = "run-as-non-root"
+	ControlRestrictedCapabilities   = "restricted-capabilities"
+	ControlRestrictedSeccomp        = "restricted-seccomp"
+)
+
+// Controls lists the controls of each level. The restricted level includes
+// the baseline controls.
+var Controls = map[string][]string{
+	LevelBaseline: {
+		ControlPrivileged,
+		ControlHostPID,
+		ControlHostIPC,
+		ControlHostNetwork,
+		ControlCapabilities,
+		ControlSeccomp,
+	},
+	LevelRestricted: {
+		ControlPrivileged,
+		ControlHostPID,
+		ControlHostIPC,
+		ControlHostNetwork,
+		ControlCapabilities,
+		ControlSeccomp,
+		ControlAllowPrivilegeEscalation,
+		ControlRunAsNonRoot,
+		ControlRestrictedCapabilities,
+		ControlRestrictedSeccomp,
+	},
+}
+
+// baselineCapabilities may be added under the baseline level.
+var baselineCapabilities = []corev1.Capability{
+	"AUDIT_WRITE", "CHOWN", "DAC_OVERRIDE", "FOWNER", "FSETID", "KILL", "MKNOD",
+	"NET_BIND_SERVICE", "SETFCAP", "SETGID", "SETPCAP", "SETUID", "SYS_CHROOT",
+}
+
+// Options configure Evaluate.
+type Options struct {
+	// Level is either baseline or restricted, restricted when empty.
+	Level string
+	// Controls restricts the evaluation to these controls of the level.
+	Controls []string
+	// ExemptNamespaces are namespace patterns that are not evaluated.
+	ExemptNamespaces []string
+}
+
+// Violation is a workload, and optionally one of its containers, failing a
+// control.
+type Violation struct {
+	Control   string `json:"control"`
+	Workload  string `json:"workload"`
+	Container string `json:"container,omitempty"`
+	Reason    string `json:"reason"`
+}
+
+// Result is the outcome of the evaluation.
+type Result struct {
+	Passed     bool                    `json:"passed"`
+	Level      string                  `json:"level"`
+	Controls   []string                `json:"controls"`
+	Evaluated  int                     `json:"evaluated"`
+	Violations map[string][]*Violation `json:"violations"`
+}
+
+// Text renders one line per violation in the format of the existing policies
+// controls, to be tested with use_multiple_values and the is_compliant flag.
+// Controls without violations print a single compliant line.
+func (r *Result) Text() string {
+	var lines []string
+	for _, control := range r.Controls {
+		violations := r.Violations[control]
+		if len(violations) == 0 {
+			lines = append(lines, fmt.Sprintf("**control: %s is_compliant: true", control))
+			continue
+		}
+		for _, v := range violations {
+			container := ""
+			if v.Container != "" {
+				container = " container: " + v.Container
+			}
+			lines = append(lines, fmt.Sprintf("**control: %s workload: %s%s reason: %q is_compliant: false",
+				control, v.Workload, container, v.Reason))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Evaluate checks every workload against the controls of the requested level.
+func Evaluate(workloads []*kube.Workload, opts Options) (*Result, error) {
+	level := opts.Level
+	if level == "" {
+		level = LevelRestricted
+	}
+	levelControls, ok := Controls[level]
+	if !ok {
+		return nil, fmt.Errorf("unknown pod security level %q", level)
+	}
+	controls := levelControls
+	if len(opts.Controls) > 0 {
+		for _, c := range opts.Controls {
+			if !contains(levelControls, c) {
+				return nil, fmt.Errorf("control %q is not part of the %v level", c, level)
+			}
+		}
+		controls = opts.Controls
+	}
+
+	r := &Result{Level: level, Controls: controls, Violations: map[string][]*Violation{}}
+	sort.SliceStable(workloads, func(i, j int) bool {
+		return workloads[i].String() < workloads[j].String()
+	})
+	for _, w := range workloads {
+		if kube.MatchAny(opts.ExemptNamespaces, w.Namespace) {
+			continue
+		}
+		r.Evaluated++
+		for _, control := range controls {
+			for _, v := range evaluators[control](w.Spec) {
+				v.Control = control
+				v.Workload = w.String()
+				r.Violations[control] = append(r.Violations[control], v)
+			}
+		}
+	}
+	r.Passed = true
+	for _, v := range r.Violations {
+		if len(v) > 0 {
+			r.Passed = false
+		}
+	}
+	return r, nil
+}
+
+type container struct {
+	name string
+	sc   *corev1.SecurityContext
+}
+
+func containers(spec *corev1.PodSpec) []container {
+	var cs []container
+	for _, c := range spec.InitContainers {
+		cs = append(cs, container{c.Name, c.SecurityContext})
+	}
+	for _, c := range spec.Containers {
+		cs = append(cs, container{c.Name, c.SecurityContext})
+	}
+	for _, c := range spec.EphemeralContainers {
+		cs = append(cs, container{c.Name, c.SecurityContext})
+	}
+	return cs
+}
+
+var evaluators = map[string]func(spec *corev1.PodSpec) []*Violation{
+	ControlPrivileged: func(spec *corev1.PodSpec) []*Violation {
+		var vs []*Violation
+		for _, c := range containers(spec) {
+			if c.sc != nil && c.sc.Privileged != nil && *c.sc.Privileged {
+				vs = append(vs, &Violation{Container: c.name, Reason: "privileged is true"})
+			}
+		}
+		return vs
+	},
+	ControlHostPID: func(spec *corev1.PodSpec) []*Violation {
+		if spec.HostPID {
+			return []*Violation{{Reason: "hostPID is true"}}
+		}
+		return nil
+	},
+	ControlHostIPC: func(spec *corev1.PodSpec) []*Violation {
+		if spec.HostIPC {
+			return []*Violation{{Reason: "hostIPC is true"}}
+		}
+		return nil
+	},
+	ControlHostNetwork: func(spec *corev1.PodSpec) []*Violation {
+		if spec.HostNetwork {
+			return []*Violation{{Reason: "hostNetwork is true"}}
+		}
+		return nil
+	},
+	ControlCapabilities: func(spec *corev1.PodSpec) []*Violation {
+		var vs []*Violation
+		for _, c := range containers(spec) {
+			if c.sc == nil || c.sc.Capabilities == nil {
+				continue
+			}
+			for _, capability := range c.sc.Capabilities.Add {
+				if !containsCapability(baselineCapabilities, capability) {
+					vs = append(vs, &Violation{Container: c.name, Reason: fmt.Sprintf("adds capability %v", capability)})
+				}
+			}
+		}
+		return vs
+	},
+	ControlSeccomp: func(spec *corev1.PodSpec) []*Violation {
+		var vs []*Violation
+		if sc := spec.SecurityContext; sc != nil && sc.SeccompProfile != nil && sc.SeccompProfile.Type == corev1.SeccompProfileTypeUnconfined {
+			vs = append(vs, &Violation{Reason: "pod seccompProfile is Unconfined"})
+		}
+		for _, c := range containers(spec) {
+			if c.sc != nil && c.sc.SeccompProfile != nil && c.sc.SeccompProfile.Type == corev1.SeccompProfileTypeUnconfined {
+				vs = append(vs, &Violation{Container: c.name, Reason: "seccompProfile is Unconfined"})
+			}
+		}
+		return vs
+	},
+	ControlAllowPrivilegeEscalation: func(spec *corev1.PodSpec) []*Violation {
+		var vs []*Violation
+		for _, c := range containers(spec) {
+			if c.sc == nil || c.sc.AllowPrivilegeEscalation == nil || *c.sc.AllowPrivilegeEscalation {
+				vs = append(vs, &Violation{Container: c.name, Reason: "allowPrivilegeEscalation is not false"})
+			}
+		}
+		return vs
+	},
+	ControlRunAsNonRoot: func(spec *corev1.PodSpec) []*Violation {
+		var vs []*Violation
+		podNonRoot, podUser := false, (*int64)(nil)
+		if sc := spec.SecurityContext; sc != nil {
+			podNonRoot = sc.RunAsNonRoot != nil && *sc.RunAsNonRoot
+			podUser = sc.RunAsUser
+		}
+		if podUser != nil && *podUser == 0 {
+			vs = append(vs, &Violation{Reason: "pod runAsUser is 0"})
+		}
+		for _, c := range containers(spec) {
+			nonRoot := podNonRoot
+			if c.sc != nil && c.sc.RunAsNonRoot != nil {
+				nonRoot = *c.sc.RunAsNonRoot
+			}
+			if !nonRoot {
+				vs = append(vs, &Violation{Container: c.name, Reason: "runAsNonRoot is not true"})
+			}
+			if c.sc != nil && c.sc.RunAsUser != nil && *c.sc.RunAsUser == 0 {
+				vs = append(vs, &Violation{Container: c.name, Reason: "runAsUser is 0"})
+			}
+		}
+		return vs
+	},
+	ControlRestrictedCapabilities: func(spec *corev1.PodSpec) []*Violation {
+		var vs []*Violation
+		for _, c := range containers(spec) {
+			if c.sc == nil || c.sc.Capabilities == nil || !containsCapability(c.sc.Capabilities.Drop, "ALL") {
+				vs = append(vs, &Violation{Container: c.name, Reason: "capabilities do not drop ALL"})
+			}
+			if c.sc == nil || c.sc.Capabilities == nil {
+				continue
+			}
+			for _, capability := range c.sc.Capabilities.Add {
+				if capability != "NET_BIND_SERVICE" {
+					vs = append(vs, &Violation{Container: c.name, Reason: fmt.Sprintf("adds capability %v", capability)})
+				}
+			}
+		}
+		return vs
+	},
+	ControlRestrictedSeccomp: func(spec *corev1.PodSpec) []*Violation {
+		var vs []*Violation
+		var podProfile *corev1.SeccompProfile
+		if spec.SecurityContext != nil {
+			podProfile = spec.SecurityContext.SeccompProfile
+		}
+		for _, c := range containers(spec) {
+			profile := podProfile
+			if c.sc != nil && c.sc.SeccompProfile != nil {
+				profile = c.sc.SeccompProfile
+			}
+			if profile == nil || (profile.Type != corev1.SeccompProfileTypeRuntimeDefault && profile.Type != corev1.SeccompProfileTypeLocalhost) {
+				vs = append(vs, &Violation{Container: c.name, Reason: "seccompProfile is not RuntimeDefault or Localhost"})
+			}
+		}
+		return vs
+	},
+}
+
+func containsCapability(list []corev1.Capability, c corev1.Capability) bool {
+	for _, v := range list {
+		if v == c {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package podsecurity
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rancher/security-scan/pkg/kb-summarizer/helpers/kube"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func restrictedSpec() corev1.PodSpec {
+	no, yes := false, true
+	return corev1.PodSpec{
+		SecurityContext: &corev1.PodSecurityContext{
+			RunAsNonRoot:   &yes,
+			SeccompProfile: &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+		},
+		Containers: []corev1.Container{{
+			Name: "app",
+			SecurityContext: &corev1.SecurityContext{
+				AllowPrivilegeEscalation: &no,
+				Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+			},
+		}},
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	yes := true
+	privileged := restrictedSpec()
+	privileged.HostNetwork = true
+	privileged.Containers[0].SecurityContext.Privileged = &yes
+	privileged.Containers[0].SecurityContext.Capabilities.Add = []corev1.Capability{"SYS_ADMIN"}
+
+	owner := metav1.NewControllerRef(&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "app", UID: "1"}},
+		appsv1.SchemeGroupVersion.WithKind("Deployment"))
+
+	objects := []runtime.Object{
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "app"},
+			Spec:       appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: restrictedSpec()}},
+		},
+		// owned by the deployment, evaluated through its template
+		&appsv1.ReplicaSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "app", OwnerReferences: []metav1.OwnerReference{*owner}},
+			Spec:       appsv1.ReplicaSetSpec{Template: corev1.PodTemplateSpec{Spec: privileged}},
+		},
+		&appsv1.DaemonSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "agent", Namespace: "monitoring"},
+			Spec:       appsv1.DaemonSetSpec{Template: corev1.PodTemplateSpec{Spec: privileged}},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "debug", Namespace: "default"},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "shell"}}},
+		},
+	}
+	workloads, err := kube.ListWorkloads(context.Background(), fake.NewClientset(objects...))
+	require.Nil(t, err)
+	require.Len(t, workloads, 3)
+
+	tests := []struct {
+		name       string
+		opts       Options
+		passed     bool
+		violations map[string][]string
+	}{
+		{
+			name:   "baseline",
+			opts:   Options{Level: LevelBaseline},
+			passed: false,
+			violations: map[string][]string{
+				ControlPrivileged:   {"DaemonSet/monitoring/agent"},
+				ControlHostNetwork:  {"DaemonSet/monitoring/agent"},
+				ControlCapabilities: {"DaemonSet/monitoring/agent"},
+			},
+		},
+		{
+			name:   "restricted",
+			opts:   Options{ExemptNamespaces: []string{"monitoring"}},
+			passed: false,
+			violations: map[string][]string{
+				ControlAllowPrivilegeEscalation: {"Pod/default/debug"},
+				ControlRunAsNonRoot:             {"Pod/default/debug"},
+				ControlRestrictedCapabilities:   {"Pod/default/debug"},
+				ControlRestrictedSeccomp:        {"Pod/default/debug"},
+			},
+		},
+		{
+			name:       "single control",
+			opts:       Options{Level: LevelBaseline, Controls: []string{ControlHostPID}},
+			passed:     true,
+			violations: map[string][]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := Evaluate(workloads, tt.opts)
+			require.Nil(t, err)
+			assert.Equal(t, tt.passed, r.Passed)
+			got := map[string][]string{}
+			for control, vs := range r.Violations {
+				for _, v := range vs {
+					got[control] = append(got[control], v.Workload)
+				}
+			}
+			assert.Equal(t, tt.violations, got)
+		})
+	}
+
+	_, err = Evaluate(workloads, Options{Level: LevelBaseline, Controls: []string{ControlRunAsNonRoot}})
+	assert.NotNil(t, err, "restricted controls are not part of the baseline level")
+}
+
+func TestResultText(t *testing.T) {
+	r, err := Evaluate(nil, Options{Controls: []string{ControlHostIPC}})
+	require.Nil(t, err)
+	assert.Equal(t, "**control: host-ipc is_compliant: true", r.Text())
+}
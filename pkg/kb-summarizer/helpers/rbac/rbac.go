@@ -0,0 +1,440 @@
+// Package rbac computes the effective permissions granted by Roles,
+// ClusterRoles and their bindings, and reports the subjects relevant to the
+// CIS 5.1.x checks.
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	CheckClusterAdmin = "cluster-admin"
+	CheckWildcards    = "wildcards"
+	CheckSecrets      = "secrets"
+	CheckCreatePods   = "create-pods"
+
+	ClusterAdminRole = "cluster-admin"
+
+	wildcard = "*"
+
+	bootstrappingLabel = "kubernetes.io/bootstrapping"
+	bootstrappingValue = "rbac-defaults"
+)
+
+// Checks lists every check, in the order they are reported.
+var Checks = []string{CheckClusterAdmin, CheckWildcards, CheckSecrets, CheckCreatePods}
+
+// Options configure Analyze.
+type Options struct {
+	// Checks to run, all when empty.
+	Checks []string
+	// Allow holds patterns of findings that are reported but considered
+	// compliant, matched against the role, the binding and the subject of a
+	// finding. Every segment of a pattern is a path.Match pattern:
+	//
+	//   - name, e.g. helm-kube-system-rke2-*, matches the name of the binding
+	//     or the subject, in any namespace;
+	//   - Kind/name, e.g. ClusterRole/system:controller:* or
+	//     ServiceAccount/cattle-*, matches the name of an object of that kind,
+	//     in any namespace;
+	//   - Kind/namespace/name, e.g. RoleBinding/cattle-*/*, matches a namespaced
+	//     object of that kind.
+	Allow []string
+	// IncludeBootstrap also reports the bindings Kubernetes creates itself,
+	// labelled kubernetes.io/bootstrapping=rbac-defaults.
+	IncludeBootstrap bool
+}
+
+// Grant is a set of rules given to a subject by one binding. The grants of
+// the roles that are not bound have no subject nor binding.
+type Grant struct {
+	// Subject, Binding and Role are named Kind/name, or Kind/namespace/name
+	// for namespaced objects.
+	Subject string `json:"subject"`
+	Binding string `json:"binding"`
+	Role    string `json:"role"`
+	// Namespace is empty for cluster wide grants.
+	Namespace string              `json:"namespace,omitempty"`
+	Rules     []rbacv1.PolicyRule `json:"-"`
+}
+
+// Finding is a grant that matters to a check.
+type Finding struct {
+	Check     string `json:"check"`
+	Subject   string `json:"subject"`
+	Binding   string `json:"binding"`
+	Role      string `json:"role"`
+	Namespace string `json:"namespace,omitempty"`
+	Allowed   bool   `json:"allowed"`
+}
+
+// Result is the outcome of the analysis.
+type Result struct {
+	Passed   bool       `json:"passed"`
+	Checks   []string   `json:"checks"`
+	Findings []*Finding `json:"findings"`
+}
+
+// Text renders one line per finding in the format of the existing policies
+// controls, to be tested with use_multiple_values and the is_compliant flag.
+// A line is printed for every check without findings, so that the flag is
+// always found.
+func (r *Result) Text() string {
+	var lines []string
+	seen := map[string]bool{}
+	for _, f := range r.Findings {
+		seen[f.Check] = true
+		scope := "cluster"
+		if f.Namespace != "" {
+			scope = f.Namespace
+		}
+		lines = append(lines, fmt.Sprintf("**check: %s subject: %s binding: %s role: %s scope: %s is_allowed: %t is_compliant: %t",
+			f.Check, orNone(f.Subject), orNone(f.Binding), f.Role, scope, f.Allowed, f.Allowed))
+	}
+	for _, check := range r.Checks {
+		if !seen[check] {
+			lines = append(lines, fmt.Sprintf("**check: %s is_compliant: true", check))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
+
+// Snapshot holds the RBAC objects of a cluster.
+type Snapshot struct {
+	Roles               []rbacv1.Role
+	ClusterRoles        []rbacv1.ClusterRole
+	RoleBindings        []rbacv1.RoleBinding
+	ClusterRoleBindings []rbacv1.ClusterRoleBinding
+}
+
+// Load lists all the RBAC objects of the cluster, once.
+func Load(ctx context.Context, client kubernetes.Interface) (*Snapshot, error) {
+	s := &Snapshot{}
+	roles, err := client.RbacV1().Roles(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error listing roles: %w", err)
+	}
+	s.Roles = roles.Items
+	clusterRoles, err := client.RbacV1().ClusterRoles().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error listing cluster roles: %w", err)
+	}
+	s.ClusterRoles = clusterRoles.Items
+	rbs, err := client.RbacV1().RoleBindings(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error listing role bindings: %w", err)
+	}
+	s.RoleBindings = rbs.Items
+	crbs, err := client.RbacV1().ClusterRoleBindings().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error listing cluster role bindings: %w", err)
+	}
+	s.ClusterRoleBindings = crbs.Items
+	return s, nil
+}
+
+// clusterRoleRules returns the rules of every ClusterRole, with the rules of
+// aggregated ClusterRoles resolved from their selectors.
+func (s *Snapshot) clusterRoleRules() (map[string][]rbacv1.PolicyRule, error) {
+	rules := map[string][]rbacv1.PolicyRule{}
+	for _, cr := range s.ClusterRoles {
+		rules[cr.Name] = append(rules[cr.Name], cr.Rules...)
+		if cr.AggregationRule == nil {
+			continue
+		}
+		for _, ls := range cr.AggregationRule.ClusterRoleSelectors {
+			selector, err := metav1.LabelSelectorAsSelector(&ls)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing aggregation rule of cluster role %v: %w", cr.Name, err)
+			}
+			for _, other := range s.ClusterRoles {
+				if other.Name != cr.Name && selector.Matches(labels.Set(other.Labels)) {
+					rules[cr.Name] = append(rules[cr.Name], other.Rules...)
+				}
+			}
+		}
+	}
+	return rules, nil
+}
+
+// Grants resolves every binding into the rules it grants each subject.
+func (s *Snapshot) Grants(includeBootstrap bool) ([]*Grant, error) {
+	clusterRoleRules, err := s.clusterRoleRules()
+	if err != nil {
+		return nil, err
+	}
+	roleRules := map[string][]rbacv1.PolicyRule{}
+	for _, r := range s.Roles {
+		roleRules[r.Namespace+"/"+r.Name] = r.Rules
+	}
+	rulesOf := func(namespace string, ref rbacv1.RoleRef) []rbacv1.PolicyRule {
+		if ref.Kind == "ClusterRole" {
+			return clusterRoleRules[ref.Name]
+		}
+		return roleRules[namespace+"/"+ref.Name]
+	}
+
+	var grants []*Grant
+	add := func(meta metav1.ObjectMeta, kind string, ref rbacv1.RoleRef, subjects []rbacv1.Subject) {
+		if !includeBootstrap && meta.Labels[bootstrappingLabel] == bootstrappingValue {
+			return
+		}
+		binding := kind + "/" + meta.Name
+		if meta.Namespace != "" {
+			binding = kind + "/" + meta.Namespace + "/" + meta.Name
+		}
+		for _, subject := range subjects {
+			grants = append(grants, &Grant{
+				Subject:   subjectName(meta.Namespace, subject),
+				Binding:   binding,
+				Role:      roleName(meta.Namespace, ref),
+				Namespace: meta.Namespace,
+				Rules:     rulesOf(meta.Namespace, ref),
+			})
+		}
+	}
+	for _, crb := range s.ClusterRoleBindings {
+		add(crb.ObjectMeta, "ClusterRoleBinding", crb.RoleRef, crb.Subjects)
+	}
+	for _, rb := range s.RoleBindings {
+		add(rb.ObjectMeta, "RoleBinding", rb.RoleRef, rb.Subjects)
+	}
+	return grants, nil
+}
+
+// UnboundRoles returns a grant without subject for every Role and ClusterRole
+// that no binding refers to, bootstrap bindings included.
+func (s *Snapshot) UnboundRoles(includeBootstrap bool) ([]*Grant, error) {
+	clusterRoleRules, err := s.clusterRoleRules()
+	if err != nil {
+		return nil, err
+	}
+	bound := map[string]bool{}
+	for _, crb := range s.ClusterRoleBindings {
+		bound[roleName("", crb.RoleRef)] = true
+	}
+	for _, rb := range s.RoleBindings {
+		bound[roleName(rb.Namespace, rb.RoleRef)] = true
+	}
+
+	var grants []*Grant
+	add := func(meta metav1.ObjectMeta, role string, rules []rbacv1.PolicyRule) {
+		if bound[role] || (!includeBootstrap && meta.Labels[bootstrappingLabel] == bootstrappingValue) {
+			return
+		}
+		grants = append(grants, &Grant{Role: role, Namespace: meta.Namespace, Rules: rules})
+	}
+	for _, cr := range s.ClusterRoles {
+		add(cr.ObjectMeta, roleName("", rbacv1.RoleRef{Kind: "ClusterRole", Name: cr.Name}), clusterRoleRules[cr.Name])
+	}
+	for _, r := range s.Roles {
+		add(r.ObjectMeta, roleName(r.Namespace, rbacv1.RoleRef{Kind: "Role", Name: r.Name}), r.Rules)
+	}
+	return grants, nil
+}
+
+func roleName(bindingNamespace string, ref rbacv1.RoleRef) string {
+	if ref.Kind == "ClusterRole" {
+		return ref.Kind + "/" + ref.Name
+	}
+	return ref.Kind + "/" + bindingNamespace + "/" + ref.Name
+}
+
+func subjectName(bindingNamespace string, s rbacv1.Subject) string {
+	if s.Kind != rbacv1.ServiceAccountKind {
+		return s.Kind + "/" + s.Name
+	}
+	namespace := s.Namespace
+	if namespace == "" {
+		namespace = bindingNamespace
+	}
+	return s.Kind + "/" + namespace + "/" + s.Name
+}
+
+// Analyze runs the requested checks against the snapshot.
+func Analyze(s *Snapshot, opts Options) (*Result, error) {
+	checks := opts.Checks
+	if len(checks) == 0 {
+		checks = Checks
+	}
+	for _, c := range checks {
+		if matches[c] == nil {
+			return nil, fmt.Errorf("unknown rbac check %q", c)
+		}
+	}
+	grants, err := s.Grants(opts.IncludeBootstrap)
+	if err != nil {
+		return nil, err
+	}
+	// a role with wildcards is reported even when it is not bound yet, the
+	// other checks are about what the subjects can do
+	unbound, err := s.UnboundRoles(opts.IncludeBootstrap)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Result{Passed: true, Checks: checks, Findings: []*Finding{}}
+	seen := map[string]bool{}
+	for _, check := range checks {
+		candidates := grants
+		if check == CheckWildcards {
+			candidates = append(append([]*Grant{}, grants...), unbound...)
+		}
+		for _, g := range candidates {
+			if !matches[check](g) {
+				continue
+			}
+			key := check + "|" + g.Subject + "|" + g.Binding + "|" + g.Role
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			f := &Finding{
+				Check:     check,
+				Subject:   g.Subject,
+				Binding:   g.Binding,
+				Role:      g.Role,
+				Namespace: g.Namespace,
+				Allowed:   allowed(opts.Allow, g),
+			}
+			if !f.Allowed {
+				r.Passed = false
+			}
+			r.Findings = append(r.Findings, f)
+		}
+	}
+	sort.SliceStable(r.Findings, func(i, j int) bool {
+		a, b := r.Findings[i], r.Findings[j]
+		if a.Check != b.Check {
+			return indexOf(checks, a.Check) < indexOf(checks, b.Check)
+		}
+		if a.Subject != b.Subject {
+			return a.Subject < b.Subject
+		}
+		if a.Binding != b.Binding {
+			return a.Binding < b.Binding
+		}
+		return a.Role < b.Role
+	})
+	return r, nil
+}
+
+var matches = map[string]func(g *Grant) bool{
+	CheckClusterAdmin: func(g *Grant) bool {
+		return g.Role == "ClusterRole/"+ClusterAdminRole || allows(g.Rules, wildcard, wildcard, wildcard)
+	},
+	CheckWildcards: func(g *Grant) bool {
+		for _, rule := range g.Rules {
+			if contains(rule.Verbs, wildcard) || contains(rule.Resources, wildcard) {
+				return true
+			}
+		}
+		return false
+	},
+	CheckSecrets: func(g *Grant) bool {
+		return allows(g.Rules, "get", "", "secrets") || allows(g.Rules, "list", "", "secrets") || allows(g.Rules, "watch", "", "secrets")
+	},
+	CheckCreatePods: func(g *Grant) bool {
+		return allows(g.Rules, "create", "", "pods")
+	},
+}
+
+// allows reports whether any rule permits verb on resource in apiGroup.
+// Wildcards in the rules match anything, a wildcard argument only matches a
+// wildcard rule.
+func allows(rules []rbacv1.PolicyRule, verb, apiGroup, resource string) bool {
+	for _, rule := range rules {
+		if len(rule.ResourceNames) > 0 {
+			continue
+		}
+		if covers(rule.Verbs, verb) && covers(rule.APIGroups, apiGroup) && covers(rule.Resources, resource) {
+			return true
+		}
+	}
+	return false
+}
+
+func covers(list []string, s string) bool {
+	return contains(list, wildcard) || contains(list, s)
+}
+
+// allowed reports whether any pattern matches the role, binding or subject of
+// the grant, see Options.Allow. Bare names only match the binding and subject.
+func allowed(patterns []string, g *Grant) bool {
+	for _, p := range patterns {
+		if matchObject(p, g.Role, false) || matchObject(p, g.Binding, true) || matchObject(p, g.Subject, true) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchObject matches a Kind/name or Kind/namespace/name object against an
+// allow pattern. Only the objects of a namespaced kind are split on their
+// second slash, so that user and group names may hold one.
+func matchObject(pattern, object string, bare bool) bool {
+	if object == "" {
+		return false
+	}
+	kind, name, _ := strings.Cut(object, "/")
+	namespace := ""
+	if namespacedKinds[kind] {
+		namespace, name, _ = strings.Cut(name, "/")
+	}
+	patternKind, rest, qualified := strings.Cut(pattern, "/")
+	if !qualified {
+		return bare && match(pattern, name)
+	}
+	if !match(patternKind, kind) {
+		return false
+	}
+	if patternNamespace, patternName, ok := strings.Cut(rest, "/"); ok && namespacedKinds[kind] {
+		return match(patternNamespace, namespace) && match(patternName, name)
+	}
+	return match(rest, name)
+}
+
+var namespacedKinds = map[string]bool{
+	"Role":                    true,
+	"RoleBinding":             true,
+	rbacv1.ServiceAccountKind: true,
+}
+
+func match(pattern, s string) bool {
+	ok, err := path.Match(pattern, s)
+	return err == nil && ok
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func indexOf(list []string, s string) int {
+	for i, v := range list {
+		if v == s {
+			return i
+		}
+	}
+	return len(list)
+}
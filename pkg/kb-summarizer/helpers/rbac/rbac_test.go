@@ -0,0 +1,271 @@
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func testObjects() []runtime.Object {
+	return []runtime.Object{
+		&rbacv1.ClusterRole{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster-admin"},
+			Rules:      []rbacv1.PolicyRule{{Verbs: []string{"*"}, APIGroups: []string{"*"}, Resources: []string{"*"}}},
+		},
+		&rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "helm-kube-system-rke2-canal"},
+			RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "cluster-admin"},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: "helm-rke2-canal", Namespace: "kube-system"}},
+		},
+		&rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "ops", Labels: map[string]string{}},
+			RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "cluster-admin"},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "alice"}},
+		},
+		// aggregated role, the rules are only present on the labelled role
+		&rbacv1.ClusterRole{
+			ObjectMeta: metav1.ObjectMeta{Name: "monitoring"},
+			AggregationRule: &rbacv1.AggregationRule{
+				ClusterRoleSelectors: []metav1.LabelSelector{{MatchLabels: map[string]string{"aggregate-to-monitoring": "true"}}},
+			},
+		},
+		&rbacv1.ClusterRole{
+			ObjectMeta: metav1.ObjectMeta{Name: "secret-reader", Labels: map[string]string{"aggregate-to-monitoring": "true"}},
+			Rules:      []rbacv1.PolicyRule{{Verbs: []string{"get", "list"}, APIGroups: []string{""}, Resources: []string{"secrets"}}},
+		},
+		&rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "monitoring"},
+			RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "monitoring"},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "system:authenticated"}},
+		},
+		&rbacv1.Role{
+			ObjectMeta: metav1.ObjectMeta{Name: "deployer", Namespace: "app"},
+			Rules:      []rbacv1.PolicyRule{{Verbs: []string{"create"}, APIGroups: []string{""}, Resources: []string{"pods"}}},
+		},
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "deployer", Namespace: "app"},
+			RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "deployer"},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: "ci"}},
+		},
+		// bootstrap bindings are ignored by default
+		&rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster-admin", Labels: map[string]string{bootstrappingLabel: bootstrappingValue}},
+			RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "cluster-admin"},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "system:masters"}},
+		},
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	s, err := Load(context.Background(), fake.NewClientset(testObjects()...))
+	require.Nil(t, err)
+
+	tests := []struct {
+		name     string
+		opts     Options
+		passed   bool
+		findings []string
+	}{
+		{
+			name:   "cluster-admin",
+			opts:   Options{Checks: []string{CheckClusterAdmin}, Allow: []string{"helm-kube-system-rke2-*"}},
+			passed: false,
+			findings: []string{
+				"ServiceAccount/kube-system/helm-rke2-canal ClusterRoleBinding/helm-kube-system-rke2-canal true",
+				"User/alice ClusterRoleBinding/ops false",
+			},
+		},
+		{
+			name:   "cluster-admin with bootstrap bindings",
+			opts:   Options{Checks: []string{CheckClusterAdmin}, Allow: []string{"helm-kube-system-rke2-*", "alice"}, IncludeBootstrap: true},
+			passed: false,
+			findings: []string{
+				"Group/system:masters ClusterRoleBinding/cluster-admin false",
+				"ServiceAccount/kube-system/helm-rke2-canal ClusterRoleBinding/helm-kube-system-rke2-canal true",
+				"User/alice ClusterRoleBinding/ops true",
+			},
+		},
+		{
+			name:   "secrets through aggregated role",
+			opts:   Options{Checks: []string{CheckSecrets}, Allow: []string{"ClusterRole/cluster-admin"}},
+			passed: false,
+			findings: []string{
+				"Group/system:authenticated ClusterRoleBinding/monitoring false",
+				"ServiceAccount/kube-system/helm-rke2-canal ClusterRoleBinding/helm-kube-system-rke2-canal true",
+				"User/alice ClusterRoleBinding/ops true",
+			},
+		},
+		{
+			name:   "create pods",
+			opts:   Options{Checks: []string{CheckCreatePods}, Allow: []string{"ClusterRole/cluster-admin"}},
+			passed: false,
+			findings: []string{
+				"ServiceAccount/app/ci RoleBinding/app/deployer false",
+				"ServiceAccount/kube-system/helm-rke2-canal ClusterRoleBinding/helm-kube-system-rke2-canal true",
+				"User/alice ClusterRoleBinding/ops true",
+			},
+		},
+		{
+			name:   "wildcards allowed",
+			opts:   Options{Checks: []string{CheckWildcards}, Allow: []string{"ClusterRole/cluster-admin"}},
+			passed: true,
+			findings: []string{
+				"ServiceAccount/kube-system/helm-rke2-canal ClusterRoleBinding/helm-kube-system-rke2-canal true",
+				"User/alice ClusterRoleBinding/ops true",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := Analyze(s, tt.opts)
+			require.Nil(t, err)
+			assert.Equal(t, tt.passed, r.Passed)
+			var got []string
+			for _, f := range r.Findings {
+				got = append(got, fmt.Sprintf("%s %s %t", f.Subject, f.Binding, f.Allowed))
+			}
+			assert.Equal(t, tt.findings, got)
+		})
+	}
+}
+
+func TestAnalyze_unboundRoles(t *testing.T) {
+	wildcards := []rbacv1.PolicyRule{{Verbs: []string{"*"}, APIGroups: []string{""}, Resources: []string{"configmaps"}}}
+	objects := append(testObjects(),
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "unused"}, Rules: wildcards},
+		&rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Name: "unused", Namespace: "app"}, Rules: wildcards},
+		&rbacv1.ClusterRole{
+			ObjectMeta: metav1.ObjectMeta{Name: "system:unused", Labels: map[string]string{bootstrappingLabel: bootstrappingValue}},
+			Rules:      wildcards,
+		},
+		// bound in another namespace only
+		&rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Name: "deployer", Namespace: "web"}, Rules: wildcards},
+	)
+	s, err := Load(context.Background(), fake.NewClientset(objects...))
+	require.Nil(t, err)
+
+	tests := []struct {
+		name     string
+		opts     Options
+		passed   bool
+		findings []string
+	}{
+		{
+			name:   "wildcards",
+			opts:   Options{Checks: []string{CheckWildcards}, Allow: []string{"ClusterRole/cluster-admin", "Role/web/*"}},
+			passed: false,
+			findings: []string{
+				"ClusterRole/unused false",
+				"Role/app/unused false",
+				"Role/web/deployer true",
+				"ClusterRole/cluster-admin true",
+				"ClusterRole/cluster-admin true",
+			},
+		},
+		{
+			name:   "wildcards with bootstrap roles",
+			opts:   Options{Checks: []string{CheckWildcards}, Allow: []string{"ClusterRole/*", "Role/*"}, IncludeBootstrap: true},
+			passed: true,
+			findings: []string{
+				"ClusterRole/system:unused true",
+				"ClusterRole/unused true",
+				"Role/app/unused true",
+				"Role/web/deployer true",
+				"ClusterRole/cluster-admin true",
+				"ClusterRole/cluster-admin true",
+				"ClusterRole/cluster-admin true",
+			},
+		},
+		{
+			name:   "unbound roles grant nothing to the other checks",
+			opts:   Options{Checks: []string{CheckCreatePods}},
+			passed: false,
+			findings: []string{
+				"Role/app/deployer false",
+				"ClusterRole/cluster-admin false",
+				"ClusterRole/cluster-admin false",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := Analyze(s, tt.opts)
+			require.Nil(t, err)
+			assert.Equal(t, tt.passed, r.Passed)
+			var got []string
+			for _, f := range r.Findings {
+				got = append(got, fmt.Sprintf("%s %t", f.Role, f.Allowed))
+			}
+			assert.Equal(t, tt.findings, got)
+		})
+	}
+
+	r, err := Analyze(s, Options{Checks: []string{CheckWildcards}, Allow: []string{"ClusterRole/*", "Role/web/*"}})
+	require.Nil(t, err)
+	assert.Contains(t, r.Text(), "**check: wildcards subject: none binding: none role: Role/app/unused scope: app is_allowed: false is_compliant: false")
+}
+
+func TestResultText(t *testing.T) {
+	s, err := Load(context.Background(), fake.NewClientset())
+	require.Nil(t, err)
+	r, err := Analyze(s, Options{Checks: []string{CheckSecrets}})
+	require.Nil(t, err)
+	assert.True(t, r.Passed)
+	assert.Equal(t, "**check: secrets is_compliant: true", r.Text())
+
+	_, err = Analyze(s, Options{Checks: []string{"unknown"}})
+	assert.NotNil(t, err)
+}
+
+func TestAllowed(t *testing.T) {
+	namespaced := &Grant{
+		Subject: "ServiceAccount/cattle-system/cattle-impersonation-u-abc",
+		Binding: "RoleBinding/cattle-system/rb-abc",
+		Role:    "Role/cattle-system/impersonator",
+	}
+	cluster := &Grant{
+		Subject: "User/https://issuer/alice",
+		Binding: "ClusterRoleBinding/globaladmin-user-abc",
+		Role:    "ClusterRole/cluster-admin",
+	}
+
+	tests := []struct {
+		pattern string
+		grant   *Grant
+		allowed bool
+	}{
+		{pattern: "rb-*", grant: namespaced, allowed: true},
+		{pattern: "cattle-impersonation-*", grant: namespaced, allowed: true},
+		{pattern: "impersonator", grant: namespaced, allowed: false},
+		{pattern: "RoleBinding/*", grant: namespaced, allowed: true},
+		{pattern: "RoleBinding/cattle-*/*", grant: namespaced, allowed: true},
+		{pattern: "RoleBinding/cattle-system/rb-*", grant: namespaced, allowed: true},
+		{pattern: "RoleBinding/default/*", grant: namespaced, allowed: false},
+		{pattern: "ServiceAccount/cattle-*", grant: namespaced, allowed: true},
+		{pattern: "ServiceAccount/cattle-system", grant: namespaced, allowed: false},
+		{pattern: "Role/impersonator", grant: namespaced, allowed: true},
+		{pattern: "Role/cattle-system/impersonator", grant: namespaced, allowed: true},
+		{pattern: "ClusterRole/impersonator", grant: namespaced, allowed: false},
+		{pattern: "*/*/rb-abc", grant: namespaced, allowed: true},
+		{pattern: "globaladmin-*", grant: cluster, allowed: true},
+		{pattern: "ClusterRoleBinding/globaladmin-*", grant: cluster, allowed: true},
+		{pattern: "ClusterRoleBinding/cattle-system/globaladmin-*", grant: cluster, allowed: false},
+		{pattern: "ClusterRole/cluster-admin", grant: cluster, allowed: true},
+		{pattern: "cluster-admin", grant: cluster, allowed: false},
+		{pattern: "User/https://issuer/alice", grant: cluster, allowed: true},
+		{pattern: "User/*", grant: cluster, allowed: false},
+		{pattern: "[", grant: cluster, allowed: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			assert.Equal(t, tt.allowed, allowed([]string{tt.pattern}, tt.grant))
+		})
+	}
+}
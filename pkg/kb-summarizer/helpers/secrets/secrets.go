@@ -0,0 +1,156 @@
+// Package secrets finds the workloads consuming Secrets as environment
+// variables, giving the CIS 5.4.1 check, which prefers Secrets mounted as
+// files, something to automate on.
+package secrets
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rancher/security-scan/pkg/kb-summarizer/helpers/kube"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// ViaEnv is a single variable set from env[].valueFrom.secretKeyRef.
+	ViaEnv = "env"
+	// ViaEnvFrom is every key of a Secret set from envFrom[].secretRef.
+	ViaEnvFrom = "envFrom"
+
+	DistroGeneric = "generic"
+	DistroRKE2    = "rke2"
+	DistroK3s     = "k3s"
+)
+
+// DefaultExemptNamespaces are the system namespaces whose workloads are
+// managed by the distribution and left out of the scan.
+var DefaultExemptNamespaces = []string{"kube-system", "kube-public", "kube-node-lease"}
+
+// distroExemptions are the namespaces of the workloads a distribution, or
+// Rancher managing it, installs with Secrets in their environment.
+var distroExemptions = map[string][]string{
+	DistroGeneric: {},
+	DistroRKE2:    {"cattle-*", "fleet-*", "calico-system", "tigera-operator"},
+	DistroK3s:     {"cattle-*", "fleet-*"},
+}
+
+// Options configure Scan.
+type Options struct {
+	// Distro adds the exemptions of the distribution, generic when empty.
+	Distro string
+	// ExemptNamespaces are namespace patterns that are not scanned, on top of
+	// the distribution defaults.
+	ExemptNamespaces []string
+}
+
+// Usage is a container of a workload consuming a Secret as environment
+// variables.
+type Usage struct {
+	Workload  string `json:"workload"`
+	Container string `json:"container"`
+	Secret    string `json:"secret"`
+	Via       string `json:"via"`
+	// Variable is the name of the variable for env, or the optional prefix
+	// for envFrom.
+	Variable string `json:"variable,omitempty"`
+}
+
+// Result is the outcome of the scan.
+type Result struct {
+	Passed  bool     `json:"passed"`
+	Scanned int      `json:"scanned"`
+	Usages  []*Usage `json:"usages"`
+}
+
+// Text renders one line per usage in the format of the existing policies
+// controls, to be tested with use_multiple_values and the is_compliant flag.
+// A single compliant line is printed when no usage was found.
+func (r *Result) Text() string {
+	if len(r.Usages) == 0 {
+		return "**secrets as environment variables: none is_compliant: true"
+	}
+	lines := make([]string, 0, len(r.Usages))
+	for _, u := range r.Usages {
+		variable := ""
+		if u.Variable != "" {
+			variable = " variable: " + u.Variable
+		}
+		lines = append(lines, fmt.Sprintf("**workload: %s container: %s secret: %s via: %s%s is_compliant: false",
+			u.Workload, u.Container, u.Secret, u.Via, variable))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Scan reports every container of the workloads referencing a Secret from
+// its environment.
+func Scan(workloads []*kube.Workload, opts Options) (*Result, error) {
+	distro := opts.Distro
+	if distro == "" {
+		distro = DistroGeneric
+	}
+	defaults, ok := distroExemptions[distro]
+	if !ok {
+		return nil, fmt.Errorf("unknown distro %q", distro)
+	}
+	exempt := append(append([]string{}, defaults...), opts.ExemptNamespaces...)
+
+	r := &Result{Usages: []*Usage{}}
+	sort.SliceStable(workloads, func(i, j int) bool {
+		return workloads[i].String() < workloads[j].String()
+	})
+	for _, w := range workloads {
+		if kube.MatchAny(exempt, w.Namespace) {
+			continue
+		}
+		r.Scanned++
+		for _, c := range containers(w.Spec) {
+			for _, env := range c.env {
+				if env.ValueFrom == nil || env.ValueFrom.SecretKeyRef == nil {
+					continue
+				}
+				r.Usages = append(r.Usages, &Usage{
+					Workload:  w.String(),
+					Container: c.name,
+					Secret:    env.ValueFrom.SecretKeyRef.Name,
+					Via:       ViaEnv,
+					Variable:  env.Name,
+				})
+			}
+			for _, envFrom := range c.envFrom {
+				if envFrom.SecretRef == nil {
+					continue
+				}
+				r.Usages = append(r.Usages, &Usage{
+					Workload:  w.String(),
+					Container: c.name,
+					Secret:    envFrom.SecretRef.Name,
+					Via:       ViaEnvFrom,
+					Variable:  envFrom.Prefix,
+				})
+			}
+		}
+	}
+	r.Passed = len(r.Usages) == 0
+	return r, nil
+}
+
+type container struct {
+	name    string
+	env     []corev1.EnvVar
+	envFrom []corev1.EnvFromSource
+}
+
+func containers(spec *corev1.PodSpec) []container {
+	var cs []container
+	for _, c := range spec.InitContainers {
+		cs = append(cs, container{c.Name, c.Env, c.EnvFrom})
+	}
+	for _, c := range spec.Containers {
+		cs = append(cs, container{c.Name, c.Env, c.EnvFrom})
+	}
+	for _, c := range spec.EphemeralContainers {
+		cs = append(cs, container{c.Name, c.Env, c.EnvFrom})
+	}
+	return cs
+}
@@ -0,0 +1,197 @@
+package secrets
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rancher/security-scan/pkg/kb-summarizer/helpers/kube"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestScan(t *testing.T) {
+	secretEnv := corev1.EnvVar{
+		Name: "PASSWORD",
+		ValueFrom: &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "db"}, Key: "password"},
+		},
+	}
+	secretEnvFrom := corev1.EnvFromSource{
+		Prefix:    "API_",
+		SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "api"}},
+	}
+	configEnvFrom := corev1.EnvFromSource{
+		ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "settings"}},
+	}
+
+	objects := []runtime.Object{
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "app"},
+			Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+				InitContainers: []corev1.Container{{Name: "migrate", Env: []corev1.EnvVar{secretEnv}}},
+				Containers:     []corev1.Container{{Name: "app", EnvFrom: []corev1.EnvFromSource{configEnvFrom, secretEnvFrom}}},
+			}}},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "files", Namespace: "app"},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "app", Env: []corev1.EnvVar{{Name: "MODE", Value: "files"}}}},
+				Volumes: []corev1.Volume{{Name: "db", VolumeSource: corev1.VolumeSource{
+					Secret: &corev1.SecretVolumeSource{SecretName: "db"},
+				}}},
+			},
+		},
+		&appsv1.DaemonSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "cni", Namespace: "kube-system"},
+			Spec: appsv1.DaemonSetSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "agent", Env: []corev1.EnvVar{secretEnv}}},
+			}}},
+		},
+	}
+	workloads, err := kube.ListWorkloads(context.Background(), fake.NewClientset(objects...))
+	require.Nil(t, err)
+
+	tests := []struct {
+		name    string
+		opts    Options
+		scanned int
+		usages  []string
+	}{
+		{
+			name:    "default exemptions",
+			opts:    Options{ExemptNamespaces: DefaultExemptNamespaces},
+			scanned: 2,
+			usages: []string{
+				"Deployment/app/web migrate db env",
+				"Deployment/app/web app api envFrom",
+			},
+		},
+		{
+			name:    "no exemptions",
+			scanned: 3,
+			usages: []string{
+				"DaemonSet/kube-system/cni agent db env",
+				"Deployment/app/web migrate db env",
+				"Deployment/app/web app api envFrom",
+			},
+		},
+		{
+			name:    "exempt app",
+			opts:    Options{ExemptNamespaces: []string{"kube-*", "app"}},
+			scanned: 0,
+			usages:  []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := Scan(workloads, tt.opts)
+			require.Nil(t, err)
+			assert.Equal(t, tt.scanned, r.Scanned)
+			assert.Equal(t, len(tt.usages) == 0, r.Passed)
+			usages := []string{}
+			for _, u := range r.Usages {
+				usages = append(usages, u.Workload+" "+u.Container+" "+u.Secret+" "+u.Via)
+			}
+			assert.Equal(t, tt.usages, usages)
+		})
+	}
+}
+
+func TestScan_distro(t *testing.T) {
+	secretEnv := corev1.EnvVar{
+		Name: "CATTLE_TOKEN",
+		ValueFrom: &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "token"}, Key: "token"},
+		},
+	}
+	deployment := func(namespace, name string) runtime.Object {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: name, Env: []corev1.EnvVar{secretEnv}}},
+			}}},
+		}
+	}
+	workloads, err := kube.ListWorkloads(context.Background(), fake.NewClientset(
+		deployment("kube-system", "rke2-metrics-server"),
+		deployment("cattle-system", "cattle-cluster-agent"),
+		deployment("cattle-fleet-system", "fleet-agent"),
+		deployment("fleet-default", "fleet-controller"),
+		deployment("calico-system", "calico-kube-controllers"),
+		deployment("tigera-operator", "tigera-operator"),
+		deployment("app", "web"),
+	))
+	require.Nil(t, err)
+
+	tests := []struct {
+		name   string
+		opts   Options
+		usages []string
+	}{
+		{
+			name: "generic",
+			opts: Options{ExemptNamespaces: DefaultExemptNamespaces},
+			usages: []string{
+				"Deployment/app/web",
+				"Deployment/calico-system/calico-kube-controllers",
+				"Deployment/cattle-fleet-system/fleet-agent",
+				"Deployment/cattle-system/cattle-cluster-agent",
+				"Deployment/fleet-default/fleet-controller",
+				"Deployment/tigera-operator/tigera-operator",
+			},
+		},
+		{
+			name:   "rke2",
+			opts:   Options{Distro: DistroRKE2, ExemptNamespaces: DefaultExemptNamespaces},
+			usages: []string{"Deployment/app/web"},
+		},
+		{
+			name: "k3s",
+			opts: Options{Distro: DistroK3s, ExemptNamespaces: DefaultExemptNamespaces},
+			usages: []string{
+				"Deployment/app/web",
+				"Deployment/calico-system/calico-kube-controllers",
+				"Deployment/tigera-operator/tigera-operator",
+			},
+		},
+		{
+			name: "rke2 without the default exemptions",
+			opts: Options{Distro: DistroRKE2},
+			usages: []string{
+				"Deployment/app/web",
+				"Deployment/kube-system/rke2-metrics-server",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := Scan(workloads, tt.opts)
+			require.Nil(t, err)
+			usages := []string{}
+			for _, u := range r.Usages {
+				usages = append(usages, u.Workload)
+			}
+			assert.Equal(t, tt.usages, usages)
+		})
+	}
+
+	_, err = Scan(workloads, Options{Distro: "unknown"})
+	assert.NotNil(t, err)
+}
+
+func TestResultText(t *testing.T) {
+	r := &Result{Passed: true}
+	assert.Equal(t, "**secrets as environment variables: none is_compliant: true", r.Text())
+
+	r = &Result{Usages: []*Usage{
+		{Workload: "Pod/app/a", Container: "c", Secret: "db", Via: ViaEnv, Variable: "PASSWORD"},
+		{Workload: "Pod/app/a", Container: "c", Secret: "api", Via: ViaEnvFrom},
+	}}
+	assert.Equal(t, "**workload: Pod/app/a container: c secret: db via: env variable: PASSWORD is_compliant: false\n"+
+		"**workload: Pod/app/a container: c secret: api via: envFrom is_compliant: false", r.Text())
+}
@@ -6,16 +6,19 @@ import (
 	"log/slog"
 	"os/exec"
 	"sort"
+	"strings"
 
+	kb "github.com/aquasecurity/kube-bench/check"
 	"github.com/rancher/security-scan/pkg/kb-summarizer/summarizer"
 )
 
 type NodeType string
 
 const (
-	NodeTypeEtcd   NodeType = "etcd"
-	NodeTypeMaster NodeType = "master"
-	NodeTypeNode   NodeType = "node"
+	NodeTypeEtcd    NodeType = "etcd"
+	NodeTypeMaster  NodeType = "master"
+	NodeTypeNode    NodeType = "node"
+	NodeTypeCluster NodeType = "cluster"
 )
 
 type State string
@@ -29,6 +32,18 @@ const (
 	NotApplicable State = "notApplicable"
 )
 
+// Provenance tells which configuration overrode the state of a check, so a
+// check skipped by the user can be told apart from one Rancher considers not
+// applicable.
+type Provenance string
+
+const (
+	ProvenanceBenchmark     Provenance = "benchmark"
+	ProvenanceNotApplicable Provenance = "notApplicable"
+	ProvenanceDefaultSkip   Provenance = "defaultSkip"
+	ProvenanceUserSkip      Provenance = "userSkip"
+)
+
 type Check struct {
 	ID                 string            `yaml:"id" json:"id"`
 	Text               string            `json:"description"`
@@ -45,6 +60,16 @@ type Check struct {
 	ExpectedResult     string            `json:"expected_result"`
 	TestType           string            `json:"test_type"`
 	Scored             bool              `json:"scored"`
+	Provenance         Provenance        `json:"provenance,omitempty"`
+	ProvenanceReason   string            `json:"provenance_reason,omitempty"`
+	// Exception is the user skip exception skipping the check.
+	Exception *summarizer.Exception `json:"exception,omitempty"`
+	// NotRegradeable is the reason the results of the check were kept when
+	// regrading the report.
+	NotRegradeable string `json:"not_regradeable,omitempty"`
+	// HostStates is the state of a cluster check on each master that
+	// evaluated it, when they disagree.
+	HostStates map[string]State `json:"host_states,omitempty"`
 }
 
 type Group struct {
@@ -65,6 +90,22 @@ type Report struct {
 	Results       []*Group              `json:"results"`
 	// ActualValueMapData is the base64-encoded gzipped-compressed avmap data of all checks.
 	ActualValueMapData string `json:"actual_value_map_data"`
+	// ConfigWarnings are the problems found in the skip configs, such as the
+	// expired user skip exceptions or the checks that are not part of the
+	// benchmark.
+	ConfigWarnings []string `json:"configWarnings,omitempty"`
+	// PatternExpansions list the checks each pattern of the skip configs
+	// matches.
+	PatternExpansions []*PatternExpansion `json:"patternExpansions,omitempty"`
+	// Regraded is set when the results were graded again against the
+	// current controls instead of scanned.
+	Regraded bool `json:"regraded,omitempty"`
+}
+
+type PatternExpansion struct {
+	Config  Provenance `json:"config"`
+	Pattern string     `json:"pattern"`
+	Checks  []string   `json:"checks"`
 }
 
 func nodeTypeMapper(nodeType summarizer.NodeType) NodeType {
@@ -75,6 +116,10 @@ func nodeTypeMapper(nodeType summarizer.NodeType) NodeType {
 		return NodeTypeMaster
 	case summarizer.NodeTypeNode:
 		return NodeTypeNode
+	case summarizer.NodeTypeCluster, summarizer.NodeTypeNone:
+		// reports written before the cluster node type used none for the
+		// cluster targets
+		return NodeTypeCluster
 	}
 	return NodeTypeNode
 }
@@ -97,6 +142,49 @@ func mapState(state summarizer.State) State {
 	return Fail
 }
 
+// NodeState maps the state of a check on a host, as kept in the actual values
+// of the report.
+func NodeState(state kb.State) State {
+	switch state {
+	case kb.PASS:
+		return Pass
+	case kb.FAIL:
+		return Fail
+	case kb.WARN:
+		return Warn
+	case summarizer.SKIP:
+		return Skip
+	case summarizer.NA:
+		return NotApplicable
+	}
+	return State(strings.ToLower(string(state)))
+}
+
+func mapHostStates(states map[string]kb.State) map[string]State {
+	if states == nil {
+		return nil
+	}
+	mapped := make(map[string]State, len(states))
+	for host, state := range states {
+		mapped[host] = NodeState(state)
+	}
+	return mapped
+}
+
+func mapProvenance(provenance summarizer.Provenance) Provenance {
+	switch provenance {
+	case summarizer.ProvenanceBenchmark:
+		return ProvenanceBenchmark
+	case summarizer.ProvenanceNotApplicable:
+		return ProvenanceNotApplicable
+	case summarizer.ProvenanceDefaultSkip:
+		return ProvenanceDefaultSkip
+	case summarizer.ProvenanceUserSkip:
+		return ProvenanceUserSkip
+	}
+	return ""
+}
+
 func mapNodeType(nodeType []summarizer.NodeType) []NodeType {
 	var extNodeType []NodeType
 	for _, nt := range nodeType {
@@ -122,6 +210,11 @@ func mapCheck(intCheck *summarizer.CheckWrapper) *Check {
 		ExpectedResult:     intCheck.ExpectedResult,
 		TestType:           intCheck.Type,
 		Scored:             intCheck.Scored,
+		Provenance:         mapProvenance(intCheck.Provenance),
+		ProvenanceReason:   intCheck.ProvenanceReason,
+		Exception:          intCheck.Exception,
+		NotRegradeable:     intCheck.NotRegradeable,
+		HostStates:         mapHostStates(intCheck.HostStates),
 	}
 }
 
@@ -138,6 +231,18 @@ func mapGroup(intGroup *summarizer.GroupWrapper) *Group {
 	return extGroup
 }
 
+func mapPatternExpansions(intExpansions []*summarizer.PatternExpansion) []*PatternExpansion {
+	var extExpansions []*PatternExpansion
+	for _, e := range intExpansions {
+		extExpansions = append(extExpansions, &PatternExpansion{
+			Config:  mapProvenance(e.Config),
+			Pattern: e.Pattern,
+			Checks:  e.Checks,
+		})
+	}
+	return extExpansions
+}
+
 func mapNodes(intNodes map[summarizer.NodeType][]string) map[NodeType][]string {
 	extNodes := map[NodeType][]string{}
 	for k, v := range intNodes {
@@ -166,6 +271,9 @@ func mapReport(internalReport *summarizer.SummarizedReport) (*Report, error) {
 	externalReport.NotApplicable = internalReport.NotApplicable
 	externalReport.Nodes = mapNodes(internalReport.Nodes)
 	externalReport.ActualValueMapData = internalReport.ActualValueMapData
+	externalReport.ConfigWarnings = internalReport.ConfigWarnings
+	externalReport.PatternExpansions = mapPatternExpansions(internalReport.PatternExpansions)
+	externalReport.Regraded = internalReport.Regraded
 	return externalReport, nil
 }
 
@@ -198,3 +306,51 @@ func Get(data []byte) (*Report, error) {
 	}
 	return mapReport(internalReport)
 }
+
+// Parse reads a report, either the one written by the summarizer or the one
+// GetJSONBytes maps it to.
+func Parse(data []byte) (*Report, error) {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("error unmarshalling report: %w", err)
+	}
+	if _, ok := fields["results"]; !ok {
+		return Get(data)
+	}
+	r := &Report{}
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, fmt.Errorf("error unmarshalling report: %w", err)
+	}
+	return r, nil
+}
+
+// ActualValues decodes the actual values and the states of each node of the
+// checks of the report, by check ID.
+func (r *Report) ActualValues() (map[string]*summarizer.ActualValueCheck, error) {
+	checks := map[string]*summarizer.ActualValueCheck{}
+	if r.ActualValueMapData == "" {
+		return checks, nil
+	}
+	groups, err := summarizer.DecodeActualValueMapData(r.ActualValueMapData)
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range groups {
+		for _, c := range g.ActualValueChecks {
+			checks[c.ID] = c
+		}
+	}
+	return checks, nil
+}
+
+// Check returns the check of the report with the ID, nil when there is none.
+func (r *Report) Check(id string) *Check {
+	for _, g := range r.Results {
+		for _, c := range g.Checks {
+			if c.ID == id {
+				return c
+			}
+		}
+	}
+	return nil
+}
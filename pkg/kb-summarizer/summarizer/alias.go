@@ -0,0 +1,214 @@
+package summarizer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// AliasesFilename is the crosswalk file of a benchmark. It is a JSON file, as
+// every YAML file of a benchmark directory is a controls file. It maps the
+// IDs of the checks of the previous benchmarks which were renumbered to their
+// ID in the benchmark, or to an empty string when the check was removed:
+//
+//	{"rke2-cis-1.11": {"5.2.10": "5.2.9", "5.2.9": ""}}
+//
+// The IDs that are not listed keep their ID, even when the text of the check
+// was reworded.
+const AliasesFilename = "aliases.json"
+
+// Aliases map the benchmarks a benchmark succeeds to the renumbered IDs.
+type Aliases map[string]map[string]string
+
+// LoadAliases reads the crosswalk file of the benchmark. A benchmark without
+// a crosswalk file has no aliases.
+func LoadAliases(controlsDir, benchmark string) (Aliases, error) {
+	aliases := Aliases{}
+	aliasesFile := filepath.Clean(filepath.Join(controlsDir, benchmark, AliasesFilename))
+	data, err := os.ReadFile(aliasesFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return aliases, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading file %v: %v", aliasesFile, err)
+	}
+	if err := json.Unmarshal(data, &aliases); err != nil {
+		return nil, fmt.Errorf("error unmarshalling aliases file %v: %w", aliasesFile, err)
+	}
+	return aliases, nil
+}
+
+// Crosswalk translates the IDs of the checks of a benchmark to the ones of a
+// later benchmark, through the crosswalk files of the benchmarks in between.
+type Crosswalk struct {
+	From string
+	To   string
+	// steps are the aliases of each benchmark from From to To.
+	steps []map[string]string
+}
+
+// Translate returns the ID of the check in the target benchmark, and false
+// when the check was removed.
+func (c *Crosswalk) Translate(id string) (string, bool) {
+	for _, aliases := range c.steps {
+		newID, ok := aliases[id]
+		if !ok {
+			continue
+		}
+		if newID == "" {
+			return "", false
+		}
+		id = newID
+	}
+	return id, true
+}
+
+// FindCrosswalk returns the crosswalk from a benchmark to another, following
+// the crosswalk files back from the target benchmark. It returns nil when
+// there is none.
+func FindCrosswalk(controlsDir, from, to string) (*Crosswalk, error) {
+	if from == to {
+		return &Crosswalk{From: from, To: to}, nil
+	}
+	type path struct {
+		benchmark string
+		steps     []map[string]string
+	}
+	queue := []path{{benchmark: to}}
+	visited := map[string]bool{to: true}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		aliases, err := LoadAliases(controlsDir, p.benchmark)
+		if err != nil {
+			return nil, err
+		}
+		sources := make([]string, 0, len(aliases))
+		for source := range aliases {
+			sources = append(sources, source)
+		}
+		sort.Strings(sources)
+		for _, source := range sources {
+			if visited[source] {
+				continue
+			}
+			visited[source] = true
+			steps := append([]map[string]string{aliases[source]}, p.steps...)
+			if source == from {
+				return &Crosswalk{From: from, To: to, steps: steps}, nil
+			}
+			queue = append(queue, path{benchmark: source, steps: steps})
+		}
+	}
+	return nil, nil
+}
+
+// TranslateExceptions translates the IDs of the exceptions with the
+// crosswalk, dropping the ones of the removed checks. The patterns are left
+// as they are.
+func (c *Crosswalk) TranslateExceptions(exceptions []*Exception) []*Exception {
+	var translated []*Exception
+	for _, e := range exceptions {
+		if e == nil {
+			continue
+		}
+		p, err := ParseIDPattern(e.ID)
+		if err != nil || !p.IsExact() {
+			translated = append(translated, e)
+			continue
+		}
+		id, ok := c.Translate(e.ID)
+		if !ok {
+			slog.Warn("dropping the exception of a removed check", "id", e.ID, "from", c.From, "to", c.To)
+			continue
+		}
+		if id != e.ID {
+			slog.Info("translating the exception of a renumbered check", "id", e.ID, "newID", id, "from", c.From, "to", c.To)
+		}
+		t := *e
+		t.ID = id
+		translated = append(translated, &t)
+	}
+	return translated
+}
+
+// MigrateUserSkipConfig adds the exceptions of benchmark from of the user skip
+// config data to the config as exceptions of benchmark to, translated with
+// the crosswalk files of controlsDir. The other entries, and the format of
+// each exception, are kept.
+func MigrateUserSkipConfig(controlsDir, from, to string, data []byte) ([]byte, error) {
+	c, err := FindCrosswalk(controlsDir, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		return nil, fmt.Errorf("no crosswalk from benchmark %v to %v", from, to)
+	}
+	config := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("error unmarshalling skip config: %w", err)
+	}
+	skip := map[string][]json.RawMessage{}
+	if raw, ok := config["skip"]; ok {
+		if err := json.Unmarshal(raw, &skip); err != nil {
+			return nil, fmt.Errorf("error unmarshalling skip config: %w", err)
+		}
+	}
+	entries, ok := skip[from]
+	if !ok {
+		return nil, fmt.Errorf("skip config has no exceptions for benchmark %v", from)
+	}
+	migrated := []json.RawMessage{}
+	for _, raw := range entries {
+		entry, keep, err := c.translateRawException(raw)
+		if err != nil {
+			return nil, err
+		}
+		if keep {
+			migrated = append(migrated, entry)
+		}
+	}
+	skip[to] = migrated
+	if config["skip"], err = json.Marshal(skip); err != nil {
+		return nil, err
+	}
+	out, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+// translateRawException translates the ID of an exception of the user skip
+// config, keeping its other fields. It returns false for the exceptions of
+// the removed checks.
+func (c *Crosswalk) translateRawException(raw json.RawMessage) (json.RawMessage, bool, error) {
+	e := &Exception{}
+	if err := json.Unmarshal(raw, e); err != nil {
+		return nil, false, fmt.Errorf("error unmarshalling skip config exception %s: %w", raw, err)
+	}
+	translated := c.TranslateExceptions([]*Exception{e})
+	if len(translated) == 0 {
+		return nil, false, nil
+	}
+	id := translated[0].ID
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		out, err := json.Marshal(id)
+		return out, true, err
+	}
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, false, err
+	}
+	var err error
+	if fields["id"], err = json.Marshal(id); err != nil {
+		return nil, false, err
+	}
+	out, err := json.Marshal(fields)
+	return out, true, err
+}
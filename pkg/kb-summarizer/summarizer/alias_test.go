@@ -0,0 +1,209 @@
+package summarizer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestAliases writes the crosswalk files of the benchmarks.
+func writeTestAliases(t *testing.T, controlsDir string, aliases map[string]string) {
+	t.Helper()
+	for benchmark, content := range aliases {
+		require.Nil(t, os.MkdirAll(filepath.Join(controlsDir, benchmark), 0o750))
+		require.Nil(t, os.WriteFile(filepath.Join(controlsDir, benchmark, AliasesFilename), []byte(content), 0o600))
+	}
+}
+
+func TestFindCrosswalk(t *testing.T) {
+	controlsDir := t.TempDir()
+	writeTestAliases(t, controlsDir, map[string]string{
+		"b-1.2": `{"b-1.1": {"5.7.1": "5.6.1", "5.6.1": ""}}`,
+		"b-1.3": `{"b-1.2": {"5.2.10": "5.2.9", "5.6.1": "5.6.2"}}`,
+	})
+
+	c, err := FindCrosswalk(controlsDir, "b-1.1", "b-1.3")
+	require.Nil(t, err)
+	require.NotNil(t, c)
+	for id, want := range map[string]string{"5.7.1": "5.6.2", "5.2.10": "5.2.9", "1.1.1": "1.1.1"} {
+		got, ok := c.Translate(id)
+		assert.True(t, ok, id)
+		assert.Equal(t, want, got, id)
+	}
+	_, ok := c.Translate("5.6.1")
+	assert.False(t, ok, "removed in b-1.2")
+
+	c, err = FindCrosswalk(controlsDir, "b-1.3", "b-1.1")
+	require.Nil(t, err)
+	assert.Nil(t, c, "the crosswalks only go forward")
+
+	translated := (&Crosswalk{steps: []map[string]string{{"1.1.1": "1.1.2", "1.1.3": ""}}}).TranslateExceptions([]*Exception{
+		{ID: "1.1.1", Owner: "platform"}, {ID: "1.1.3"}, {ID: "1.1.*"},
+	})
+	assert.Equal(t, []*Exception{{ID: "1.1.2", Owner: "platform"}, {ID: "1.1.*"}}, translated)
+}
+
+func TestGetUserSkipInfo_crosswalk(t *testing.T) {
+	controlsDir := t.TempDir()
+	writeTestAliases(t, controlsDir, map[string]string{
+		"b-1.2": `{"b-1.1": {"5.7.1": "5.6.1"}}`,
+		"b-1.3": `{"b-1.2": {}}`,
+	})
+	config := writeTestFile(t, "config.json", `{"skip": {"b-1.1": ["5.7.1", "1.1.1"], "b-1.2": ["5.6.1"], "other": ["9.9.9"]}}`)
+
+	exceptions, err := GetUserSkipInfo(controlsDir, "b-1.3", config)
+	require.Nil(t, err)
+	assert.ElementsMatch(t, []string{"5.6.1"}, ids(exceptions), "the closest benchmark is translated")
+
+	exceptions, err = GetUserSkipInfo(controlsDir, "b-1.2", writeTestFile(t, "config.json", `{"skip": {"b-1.1": ["5.7.1", "1.1.1"]}}`))
+	require.Nil(t, err)
+	assert.ElementsMatch(t, []string{"5.6.1", "1.1.1"}, ids(exceptions))
+
+	exceptions, err = GetUserSkipInfo(controlsDir, "b-1.4", config)
+	require.Nil(t, err)
+	assert.Empty(t, exceptions)
+}
+
+func TestMigrateUserSkipConfig(t *testing.T) {
+	controlsDir := t.TempDir()
+	writeTestAliases(t, controlsDir, map[string]string{
+		"b-1.2": `{"b-1.1": {"5.7.1": "5.6.1", "5.2.9": ""}}`,
+	})
+	data := []byte(`{"skip": {"b-1.1": ["1.1.1", {"id": "5.7.1", "owner": "platform", "expires": "2030-01-01"}, "5.2.9", "5.2.*"]}}`)
+
+	out, err := MigrateUserSkipConfig(controlsDir, "b-1.1", "b-1.2", data)
+	require.Nil(t, err)
+	assert.JSONEq(t, `{"skip": {
+		"b-1.1": ["1.1.1", {"id": "5.7.1", "owner": "platform", "expires": "2030-01-01"}, "5.2.9", "5.2.*"],
+		"b-1.2": ["1.1.1", {"id": "5.6.1", "owner": "platform", "expires": "2030-01-01"}, "5.2.*"]
+	}}`, string(out))
+
+	_, err = MigrateUserSkipConfig(controlsDir, "b-1.2", "b-1.1", data)
+	assert.NotNil(t, err, "no crosswalk")
+	_, err = MigrateUserSkipConfig(controlsDir, "b-1.0", "b-1.0", data)
+	assert.NotNil(t, err, "no exceptions for the benchmark")
+}
+
+// cfgDir is the controls directory of the repository.
+const cfgDir = "../../../package/cfg"
+
+// checkTexts returns the normalized text of the checks of the benchmark by ID,
+// and the IDs in order.
+func checkTexts(t *testing.T, controlsDir, benchmark string) ([]string, map[string]string) {
+	t.Helper()
+	b, err := LoadBenchmark(controlsDir, benchmark)
+	require.Nil(t, err)
+	var ids []string
+	texts := map[string]string{}
+	for _, c := range b.Checks() {
+		if _, ok := texts[c.ID]; !ok {
+			ids = append(ids, c.ID)
+			texts[c.ID] = NormalizeText(c.Text)
+		}
+	}
+	return ids, texts
+}
+
+// crosswalkErrors checks the crosswalk file of the benchmark against the texts
+// of the checks. A renumbered check keeps its text, or gets a note appended
+// to it. A check that is not listed keeps its ID, its text may be reworded. A
+// removed check neither keeps its ID nor is found under a free ID.
+func crosswalkErrors(t *testing.T, controlsDir, benchmark string) []string {
+	t.Helper()
+	aliases, err := LoadAliases(controlsDir, benchmark)
+	require.Nil(t, err)
+	newIDs, newTexts := checkTexts(t, controlsDir, benchmark)
+	var errs []string
+	for source, renumbered := range aliases {
+		fail := func(format string, args ...any) {
+			errs = append(errs, fmt.Sprintf("%v from %v: ", benchmark, source)+fmt.Sprintf(format, args...))
+		}
+		oldIDs, oldTexts := checkTexts(t, controlsDir, source)
+		// the IDs of the checks of the benchmark paired with a check of the
+		// source, renumbered or not
+		renumberedTo, paired := map[string]bool{}, map[string]bool{}
+		for _, id := range oldIDs {
+			if newID, ok := renumbered[id]; !ok {
+				paired[id] = true
+			} else if newID != "" {
+				renumberedTo[newID], paired[newID] = true, true
+			}
+		}
+		for _, id := range oldIDs {
+			newID, ok := renumbered[id]
+			switch {
+			case !ok && renumberedTo[id]:
+				fail("check %v is not listed, but another check is renumbered to %v", id, id)
+			case !ok && newTexts[id] == "":
+				fail("check %v is not listed, but is not part of the benchmark", id)
+			case newID == "" && ok && newTexts[id] != "" && !paired[id]:
+				fail("check %v is removed, but keeps its ID", id)
+			case newID == "" && ok:
+				for _, other := range newIDs {
+					if !paired[other] && other != id && newTexts[other] == oldTexts[id] {
+						fail("check %v is removed, but has the text of %v", id, other)
+					}
+				}
+			case newID != "" && !strings.HasPrefix(newTexts[newID], oldTexts[id]):
+				fail("check %v is renumbered to %v, which has another text", id, newID)
+			}
+		}
+		for id := range renumbered {
+			if _, ok := oldTexts[id]; !ok {
+				fail("check %v is not part of %v", id, source)
+			}
+		}
+	}
+	return errs
+}
+
+func TestAliases_controls(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join(cfgDir, "*", AliasesFilename))
+	require.Nil(t, err)
+	require.NotEmpty(t, files)
+	for _, file := range files {
+		assert.Empty(t, crosswalkErrors(t, cfgDir, filepath.Base(filepath.Dir(file))))
+	}
+}
+
+func TestAliases_crosswalkErrors(t *testing.T) {
+	controlsDir := t.TempDir()
+	require.Nil(t, os.WriteFile(filepath.Join(controlsDir, ConfigFilename), []byte("target_mapping:\n  b-1.1:\n    - master\n  b-1.2:\n    - master\n"), 0o600))
+	controls := func(texts ...string) string {
+		c := "controls:\ntype: master\ngroups:\n  - id: \"1.1\"\n    checks:\n"
+		for i, text := range texts {
+			c += fmt.Sprintf("      - id: 1.1.%d\n        text: %q\n", i+1, text)
+		}
+		return c
+	}
+	for benchmark, content := range map[string]string{
+		"b-1.1": controls("Ensure a (Automated)", "Ensure b is 644 (Automated)", "Ensure c (Automated)", "Ensure d (Automated)"),
+		"b-1.2": controls("Ensure a (Manual)", "Ensure b is 600 (Automated)", "Ensure d (Automated)"),
+	} {
+		require.Nil(t, os.MkdirAll(filepath.Join(controlsDir, benchmark), 0o750))
+		require.Nil(t, os.WriteFile(filepath.Join(controlsDir, benchmark, "master.yaml"), []byte(content), 0o600))
+	}
+
+	for aliases, want := range map[string][]string{
+		`{"b-1.1": {"1.1.3": "", "1.1.4": "1.1.3"}}`:              nil,
+		`{"b-1.1": {"1.1.3": "", "1.1.4": "1.1.3", "1.1.2": ""}}`: {"b-1.2 from b-1.1: check 1.1.2 is removed, but keeps its ID"},
+		`{"b-1.1": {"1.1.3": "", "1.1.4": "1.1.3", "1.1.1": ""}}`: {"b-1.2 from b-1.1: check 1.1.1 is removed, but keeps its ID"},
+		`{"b-1.1": {"1.1.4": "1.1.3"}}`:                           {"b-1.2 from b-1.1: check 1.1.3 is not listed, but another check is renumbered to 1.1.3"},
+		`{"b-1.1": {"1.1.3": "", "1.1.4": ""}}`: {
+			"b-1.2 from b-1.1: check 1.1.3 is removed, but keeps its ID",
+			"b-1.2 from b-1.1: check 1.1.4 is removed, but has the text of 1.1.3",
+		},
+		`{"b-1.1": {"1.1.3": "1.1.2", "1.1.4": "1.1.3"}}`: {
+			"b-1.2 from b-1.1: check 1.1.2 is not listed, but another check is renumbered to 1.1.2",
+			"b-1.2 from b-1.1: check 1.1.3 is renumbered to 1.1.2, which has another text",
+		},
+	} {
+		writeTestAliases(t, controlsDir, map[string]string{"b-1.2": aliases})
+		assert.Equal(t, want, crosswalkErrors(t, controlsDir, "b-1.2"), aliases)
+	}
+}
@@ -0,0 +1,142 @@
+package summarizer
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	kb "github.com/aquasecurity/kube-bench/check"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// Benchmark is a benchmark of a controls directory, loaded the way the
+// summarizer loads it.
+type Benchmark struct {
+	Name    string
+	Targets []*Target
+}
+
+// Target is a target of a benchmark and its controls.
+type Target struct {
+	Name     string
+	NodeType NodeType
+	// Controls is nil when the controls file could not be loaded.
+	Controls *kb.Controls
+	Err      error
+}
+
+// BenchmarkCheck is a check of a benchmark along with its group and target.
+type BenchmarkCheck struct {
+	*kb.Check
+	Group  *kb.Group
+	Target *Target
+}
+
+// TestsYAML renders the tests of the check, along with how the audit output
+// is evaluated.
+func (c *BenchmarkCheck) TestsYAML() string {
+	if c.Tests == nil {
+		return ""
+	}
+	var b strings.Builder
+	encoder := yaml.NewEncoder(&b)
+	encoder.SetIndent(2)
+	err := encoder.Encode(struct {
+		Tests             any  `yaml:"tests"`
+		UseMultipleValues bool `yaml:"use_multiple_values,omitempty"`
+	}{c.Tests, c.IsMultiple})
+	if err != nil {
+		return fmt.Sprint(c.Tests)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// LoadBenchmark loads the controls of the targets of a benchmark of the
+// controls directory.
+func LoadBenchmark(controlsDir, benchmark string) (*Benchmark, error) {
+	s := &Summarizer{ControlsDirectory: controlsDir, BenchmarkVersion: benchmark}
+	if err := s.loadTargetMapping(); err != nil {
+		return nil, fmt.Errorf("error loading target mapping: %w", err)
+	}
+	if err := s.loadTargetNodeTypeMapping(); err != nil {
+		return nil, fmt.Errorf("error loading target node type mapping: %w", err)
+	}
+	if _, ok := s.BenchmarkToConfigMap[benchmark]; !ok {
+		return nil, fmt.Errorf("unknown benchmark %v", benchmark)
+	}
+	return &Benchmark{Name: benchmark, Targets: s.loadTargets()}, nil
+}
+
+// Checks returns the checks of the benchmark, in the order of the targets. A
+// check of several targets is returned for each of them.
+func (b *Benchmark) Checks() []*BenchmarkCheck {
+	var checks []*BenchmarkCheck
+	for _, t := range b.Targets {
+		if t.Controls == nil {
+			continue
+		}
+		for _, g := range t.Controls.Groups {
+			for _, c := range g.Checks {
+				checks = append(checks, &BenchmarkCheck{Check: c, Group: g, Target: t})
+			}
+		}
+	}
+	return checks
+}
+
+// Check returns the check of the benchmark with the ID, nil when there is
+// none.
+func (b *Benchmark) Check(id string) *BenchmarkCheck {
+	for _, c := range b.Checks() {
+		if c.ID == id {
+			return c
+		}
+	}
+	return nil
+}
+
+var (
+	profileSuffix = regexp.MustCompile(`\s*\((Automated|Manual)\)\s*$`)
+	spaces        = regexp.MustCompile(`\s+`)
+)
+
+// NormalizeText drops what changes between benchmarks without changing the
+// check from its text, i.e. the case, the spacing and the Automated or Manual
+// suffix.
+func NormalizeText(text string) string {
+	return strings.ToLower(spaces.ReplaceAllString(profileSuffix.ReplaceAllString(strings.TrimSpace(text), ""), " "))
+}
+
+// Benchmarks returns the benchmarks of the target mapping of the controls
+// directory, sorted.
+func Benchmarks(controlsDir string) ([]string, error) {
+	s := &Summarizer{ControlsDirectory: controlsDir}
+	if err := s.loadTargetMapping(); err != nil {
+		return nil, fmt.Errorf("error loading target mapping: %w", err)
+	}
+	return keys(s.BenchmarkToConfigMap), nil
+}
+
+// KubernetesVersions maps the benchmarks to the kubernetes versions of the
+// version mapping of the controls directory which select them.
+func KubernetesVersions(controlsDir string) (map[string][]string, error) {
+	configFileName := fmt.Sprintf("%s/%s", controlsDir, ConfigFilename)
+	v := viper.New()
+	v.SetConfigFile(configFileName)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("error reading in config file: %w", err)
+	}
+	// the managed services map their versions to a list of benchmarks
+	versions := map[string][]string{}
+	for version, benchmarks := range v.GetStringMapStringSlice(VersionMappingKey) {
+		for _, b := range benchmarks {
+			versions[b] = append(versions[b], version)
+		}
+	}
+	for _, vs := range versions {
+		sort.Strings(vs)
+	}
+	return versions, nil
+}
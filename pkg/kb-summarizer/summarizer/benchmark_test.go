@@ -0,0 +1,35 @@
+package summarizer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadBenchmark(t *testing.T) {
+	controlsDir := writeTestBenchmark(t,
+		testTarget{name: "master", checks: []string{"1.1.1", "1.2.1"}},
+		testTarget{name: "policies", checks: []string{"5.1.1"}},
+	)
+	b, err := LoadBenchmark(controlsDir, testBenchmark)
+	require.Nil(t, err)
+	require.Len(t, b.Targets, 2)
+	assert.Equal(t, "master", b.Targets[0].Name)
+	assert.Equal(t, NodeTypeMaster, b.Targets[0].NodeType)
+	assert.Equal(t, NodeTypeCluster, b.Targets[1].NodeType)
+
+	var ids []string
+	for _, c := range b.Checks() {
+		ids = append(ids, c.ID)
+	}
+	assert.Equal(t, []string{"1.1.1", "1.2.1", "5.1.1"}, ids)
+	c := b.Check("5.1.1")
+	require.NotNil(t, c)
+	assert.Equal(t, "policies", c.Target.Name)
+	assert.Equal(t, "5.1", c.Group.ID)
+	assert.Nil(t, b.Check("9.9.9"))
+
+	_, err = LoadBenchmark(controlsDir, "unknown")
+	assert.NotNil(t, err)
+}
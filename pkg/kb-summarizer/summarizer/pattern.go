@@ -0,0 +1,173 @@
+package summarizer
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// IDPattern matches the IDs of checks. The keys of the skip configs are ID
+// patterns, either:
+//   - an ID, 5.2.1
+//   - a group prefix, 5.2.* matching the checks of group 5.2 and its subgroups
+//   - a range of the last component, 5.2.1-5.2.13 or 5.2.1-13
+//   - a regular expression between slashes, /^5\.2\.(1|3)$/
+type IDPattern struct {
+	raw   string
+	exact bool
+	match func(id string) bool
+}
+
+// ParseIDPattern parses the ID pattern s.
+func ParseIDPattern(s string) (*IDPattern, error) {
+	p := &IDPattern{raw: s}
+	switch {
+	case s == "":
+		return nil, fmt.Errorf("empty check id")
+	case len(s) > 1 && strings.HasPrefix(s, "/") && strings.HasSuffix(s, "/"):
+		re, err := regexp.Compile(s[1 : len(s)-1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid check id regular expression %v: %w", s, err)
+		}
+		p.match = re.MatchString
+	case strings.HasSuffix(s, ".*"):
+		prefix := strings.TrimSuffix(s, "*")
+		p.match = func(id string) bool {
+			return strings.HasPrefix(id, prefix)
+		}
+	case strings.Contains(s, "-"):
+		match, err := rangeMatcher(s)
+		if err != nil {
+			return nil, err
+		}
+		p.match = match
+	default:
+		p.exact = true
+		p.match = func(id string) bool {
+			return id == s
+		}
+	}
+	return p, nil
+}
+
+// rangeMatcher matches the IDs of the range first-last, whose bounds only
+// differ in their last component.
+func rangeMatcher(s string) (func(id string) bool, error) {
+	first, last, _ := strings.Cut(s, "-")
+	dot := strings.LastIndex(first, ".")
+	prefix := first[:dot+1]
+	if strings.Contains(last, ".") {
+		if !strings.HasPrefix(last, prefix) {
+			return nil, fmt.Errorf("invalid check id range %v, the bounds must be in the same group", s)
+		}
+		last = strings.TrimPrefix(last, prefix)
+	}
+	low, err := strconv.Atoi(first[dot+1:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid check id range %v: %w", s, err)
+	}
+	high, err := strconv.Atoi(last)
+	if err != nil {
+		return nil, fmt.Errorf("invalid check id range %v: %w", s, err)
+	}
+	if low > high {
+		return nil, fmt.Errorf("invalid check id range %v, the bounds are reversed", s)
+	}
+	return func(id string) bool {
+		rest, ok := strings.CutPrefix(id, prefix)
+		if !ok {
+			return false
+		}
+		n, err := strconv.Atoi(rest)
+		return err == nil && n >= low && n <= high
+	}, nil
+}
+
+func (p *IDPattern) String() string {
+	return p.raw
+}
+
+// IsExact reports whether the pattern is a single ID.
+func (p *IDPattern) IsExact() bool {
+	return p.exact
+}
+
+// Match reports whether the pattern matches the ID.
+func (p *IDPattern) Match(id string) bool {
+	return p.match(id)
+}
+
+// parseIDPatterns parses the keys of a skip config, returning the patterns
+// other than single IDs in lexical order.
+func parseIDPatterns[T any](entries map[string]T) ([]*IDPattern, error) {
+	var patterns []*IDPattern
+	for key := range entries {
+		p, err := ParseIDPattern(key)
+		if err != nil {
+			return nil, err
+		}
+		if !p.IsExact() {
+			patterns = append(patterns, p)
+		}
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		return patterns[i].raw < patterns[j].raw
+	})
+	return patterns, nil
+}
+
+// lookupEntries returns the entries of a skip config for the ID, the entry of
+// the ID first, then the ones of the patterns in order.
+func lookupEntries[T any](entries map[string]T, patterns []*IDPattern, id string) []T {
+	var found []T
+	if e, ok := entries[id]; ok {
+		found = append(found, e)
+	}
+	for _, p := range patterns {
+		if p.Match(id) {
+			found = append(found, entries[p.raw])
+		}
+	}
+	return found
+}
+
+// lookupEntry returns the first entry of a skip config for the ID that is
+// accepted, e.g. in scope. The entry of the ID takes precedence over the
+// patterns, but a pattern still applies where the entry of the ID is not
+// accepted.
+func lookupEntry[T any](entries map[string]T, patterns []*IDPattern, id string, accept func(T) bool) (T, bool) {
+	for _, e := range lookupEntries(entries, patterns, id) {
+		if accept(e) {
+			return e, true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+// PatternExpansion lists the checks a pattern of a skip config matches.
+type PatternExpansion struct {
+	Config  Provenance `json:"c"`
+	Pattern string     `json:"p"`
+	Checks  []string   `json:"ids"`
+}
+
+// expandPatterns lists the checks of the benchmark, in order, each pattern
+// matches.
+func (s *Summarizer) expandPatterns(config Provenance, patterns []*IDPattern) []*PatternExpansion {
+	var expansions []*PatternExpansion
+	for _, p := range patterns {
+		e := &PatternExpansion{Config: config, Pattern: p.raw, Checks: []string{}}
+		for _, gw := range s.fullReport.GroupWrappers {
+			for _, cw := range gw.CheckWrappers {
+				if p.Match(cw.ID) {
+					e.Checks = append(e.Checks, cw.ID)
+				}
+			}
+		}
+		expansions = append(expansions, e)
+	}
+	return expansions
+}
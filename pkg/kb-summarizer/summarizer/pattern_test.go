@@ -0,0 +1,108 @@
+package summarizer
+
+import (
+	"testing"
+
+	kb "github.com/aquasecurity/kube-bench/check"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIDPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		exact   bool
+		match   []string
+		noMatch []string
+	}{
+		{pattern: "5.2.1", exact: true, match: []string{"5.2.1"}, noMatch: []string{"5.2.10", "5.2"}},
+		{pattern: "5.2.*", match: []string{"5.2.1", "5.2.13", "5.2.1.1"}, noMatch: []string{"5.2", "5.21.1", "5.3.1"}},
+		{pattern: "5.2.1-5.2.13", match: []string{"5.2.1", "5.2.9", "5.2.13"}, noMatch: []string{"5.2.14", "5.2.1.1", "5.3.2"}},
+		{pattern: "1.2.3-5", match: []string{"1.2.3", "1.2.5"}, noMatch: []string{"1.2.2", "1.2.6"}},
+		{pattern: `/^5\.2\.(1|3)$/`, match: []string{"5.2.1", "5.2.3"}, noMatch: []string{"5.2.2", "5.2.13"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			p, err := ParseIDPattern(tt.pattern)
+			require.Nil(t, err)
+			assert.Equal(t, tt.exact, p.IsExact())
+			for _, id := range tt.match {
+				assert.True(t, p.Match(id), id)
+			}
+			for _, id := range tt.noMatch {
+				assert.False(t, p.Match(id), id)
+			}
+		})
+	}
+
+	for _, invalid := range []string{"", "5.2.3-1", "5.2.1-5.3.2", "5.2.a-3", "/(/"} {
+		_, err := ParseIDPattern(invalid)
+		assert.NotNil(t, err, invalid)
+	}
+}
+
+func TestSummarizer_patterns(t *testing.T) {
+	controlsDir := writeTestBenchmark(t,
+		testTarget{name: "master", checks: []string{"1.2.1", "1.2.2", "1.2.3"}},
+		testTarget{name: "policies", checks: []string{"5.2.1", "5.2.2", "5.2.10"}},
+	)
+	inputDir := t.TempDir()
+	writeTestResults(t, inputDir, "m1", "master", map[string]kb.State{"1.2.1": kb.FAIL, "1.2.2": kb.FAIL, "1.2.3": kb.FAIL})
+	writeTestResults(t, inputDir, "m1", "policies", map[string]kb.State{"5.2.1": kb.FAIL, "5.2.2": kb.FAIL, "5.2.10": kb.FAIL})
+	userSkip := writeTestFile(t, "user-skip.json", `{"skip": {"`+testBenchmark+`": ["5.2.*", "9.*"]}}`)
+	notApplicable := writeTestFile(t, "not-applicable.json", `{"1.2.1-2": "range", "5.2.2": "single"}`)
+
+	s, err := NewSummarizer("", testBenchmark, controlsDir, inputDir, t.TempDir(), DefaultOutputFileName, userSkip, "", notApplicable, false)
+	require.Nil(t, err)
+	checks := summarizeTest(t, s)
+
+	assert.Equal(t, NotApplicable, checks["1.2.1"].State)
+	assert.Equal(t, NotApplicable, checks["1.2.2"].State)
+	assert.Equal(t, Fail, checks["1.2.3"].State)
+	assert.Equal(t, Skip, checks["5.2.1"].State)
+	assert.Equal(t, Skip, checks["5.2.10"].State)
+	// the not applicable config takes precedence
+	assert.Equal(t, NotApplicable, checks["5.2.2"].State)
+	assert.Equal(t, "single", checks["5.2.2"].ProvenanceReason)
+
+	assert.Equal(t, []*PatternExpansion{
+		{Config: ProvenanceNotApplicable, Pattern: "1.2.1-2", Checks: []string{"1.2.1", "1.2.2"}},
+		{Config: ProvenanceUserSkip, Pattern: "5.2.*", Checks: []string{"5.2.1", "5.2.2", "5.2.10"}},
+		{Config: ProvenanceUserSkip, Pattern: "9.*", Checks: []string{}},
+	}, s.fullReport.PatternExpansions)
+}
+
+func TestSummarizer_patternsInScope(t *testing.T) {
+	controlsDir := writeTestBenchmark(t, testTarget{name: "node", checks: []string{"4.1.1", "4.2.1", "4.3.1"}})
+	inputDir := t.TempDir()
+	writeTestResults(t, inputDir, "m1", "node", map[string]kb.State{"4.1.1": kb.FAIL, "4.2.1": kb.FAIL, "4.3.1": kb.FAIL})
+	writeTestResults(t, inputDir, "w1", "node", map[string]kb.State{"4.1.1": kb.FAIL, "4.2.1": kb.FAIL, "4.3.1": kb.FAIL})
+	// the entries of the IDs are out of scope of w1, the patterns are not
+	notApplicable := writeTestFile(t, "not-applicable.json", `{
+		"4.1.1": {"reason": "gpu pool", "nodes": ["gpu-*"]},
+		"4.1.*": {"reason": "workers", "nodes": ["w*"]}
+	}`)
+	defaultSkip := writeTestFile(t, "default-skip.json", `{
+		"4.2.1": {"reason": "gpu pool", "nodes": ["gpu-*"]},
+		"4.2.1-3": {"reason": "workers", "nodes": ["w*"]}
+	}`)
+	userSkip := writeTestFile(t, "user-skip.json", `{"skip": {"`+testBenchmark+`": [
+		{"id": "4.3.1", "justification": "gpu pool", "ticket": "SEC-1", "nodes": ["gpu-*"]},
+		{"id": "/^4\\.3\\./", "justification": "workers", "ticket": "SEC-2", "nodes": ["w*"]}
+	]}}`)
+
+	s, err := NewSummarizer("", testBenchmark, controlsDir, inputDir, t.TempDir(), DefaultOutputFileName, userSkip, defaultSkip, notApplicable, false)
+	require.Nil(t, err)
+	checks := summarizeTest(t, s)
+
+	assert.Equal(t, ProvenanceNotApplicable, checks["4.1.1"].Provenance)
+	assert.Equal(t, "workers", checks["4.1.1"].ProvenanceReason)
+	assert.Equal(t, Fail, checks["4.1.1"].State)
+	assert.Equal(t, ProvenanceDefaultSkip, checks["4.2.1"].Provenance)
+	assert.Equal(t, "workers", checks["4.2.1"].ProvenanceReason)
+	assert.Equal(t, Fail, checks["4.2.1"].State)
+	assert.Equal(t, ProvenanceUserSkip, checks["4.3.1"].Provenance)
+	assert.Equal(t, "SEC-2", checks["4.3.1"].Exception.Ticket)
+	assert.Equal(t, Fail, checks["4.3.1"].State)
+	assert.Equal(t, override{}, s.overrides["4.3.1"]["m1"], "the host out of scope of both is not overridden")
+}
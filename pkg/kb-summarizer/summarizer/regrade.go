@@ -0,0 +1,41 @@
+package summarizer
+
+import (
+	"log/slog"
+
+	kb "github.com/aquasecurity/kube-bench/check"
+)
+
+// Regrader grades the result of a check of a results file again, against the
+// current control of the check. It returns an error when the result can't be
+// regraded, e.g. when the audit command of the control changed since the
+// scan.
+type Regrader interface {
+	Regrade(control, result *kb.Check) (*kb.Check, error)
+}
+
+// regrade replaces the results of the checks of a target of a host with
+// their regraded results. The results of the checks that can't be regraded
+// are kept, and the reason is reported.
+func (s *Summarizer) regrade(results *kb.Controls, hostname, target string) {
+	if s.notRegradeable == nil {
+		s.notRegradeable = map[string]string{}
+	}
+	for _, g := range results.Groups {
+		for i, check := range g.Checks {
+			control := s.controls[target][check.ID]
+			if control == nil {
+				continue
+			}
+			regraded, err := s.Regrader.Regrade(control, check)
+			if err != nil {
+				slog.Warn("keeping the result of a check that can't be regraded", "host", hostname, "id", check.ID, "error", err)
+				if _, ok := s.notRegradeable[check.ID]; !ok {
+					s.notRegradeable[check.ID] = err.Error()
+				}
+				continue
+			}
+			g.Checks[i] = regraded
+		}
+	}
+}
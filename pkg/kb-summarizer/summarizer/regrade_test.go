@@ -0,0 +1,95 @@
+package summarizer
+
+import (
+	"errors"
+	"testing"
+
+	kb "github.com/aquasecurity/kube-bench/check"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testRegrader passes the checks of its IDs, and can't regrade the others.
+type testRegrader map[string]bool
+
+func (r testRegrader) Regrade(control, result *kb.Check) (*kb.Check, error) {
+	if !r[control.ID] {
+		return nil, errors.New("the audit command changed")
+	}
+	regraded := *control
+	regraded.State = kb.PASS
+	regraded.ActualValue = result.ActualValue
+	return &regraded, nil
+}
+
+// recordingRegrader fails every check, keeping the controls it was given.
+type recordingRegrader struct {
+	controls map[string][]kb.Check
+}
+
+func (r *recordingRegrader) Regrade(control, result *kb.Check) (*kb.Check, error) {
+	r.controls[control.ID] = append(r.controls[control.ID], *control)
+	regraded := *control
+	regraded.State = kb.FAIL
+	regraded.ActualValue = result.ActualValue
+	return &regraded, nil
+}
+
+func TestSummarizer_regrade(t *testing.T) {
+	controlsDir := writeTestBenchmark(t,
+		testTarget{name: "master", checks: []string{"1.1.1", "1.1.2"}},
+	)
+	inputDir := t.TempDir()
+	writeTestResults(t, inputDir, "m1", "master", map[string]kb.State{"1.1.1": kb.FAIL, "1.1.2": kb.FAIL})
+	writeTestResults(t, inputDir, "m2", "master", map[string]kb.State{"1.1.1": kb.FAIL, "1.1.2": kb.FAIL})
+	notApplicable := writeTestFile(t, "not-applicable.json", `{"1.1.2": "regraded or not"}`)
+
+	s, err := NewSummarizer("", testBenchmark, controlsDir, inputDir, t.TempDir(), DefaultOutputFileName, "", "", "", false)
+	require.Nil(t, err)
+	s.Regrader = testRegrader{"1.1.1": true}
+	checks := summarizeTest(t, s)
+
+	assert.True(t, s.fullReport.Regraded)
+	assert.Equal(t, Pass, checks["1.1.1"].State)
+	assert.Empty(t, checks["1.1.1"].NotRegradeable)
+	assert.Equal(t, Fail, checks["1.1.2"].State, "the scanned results are kept")
+	assert.Equal(t, "the audit command changed", checks["1.1.2"].NotRegradeable)
+
+	// the skip configs apply to the regraded results
+	s, err = NewSummarizer("", testBenchmark, controlsDir, inputDir, t.TempDir(), DefaultOutputFileName, "", "", notApplicable, false)
+	require.Nil(t, err)
+	s.Regrader = testRegrader{"1.1.1": true, "1.1.2": true}
+	checks = summarizeTest(t, s)
+	assert.Equal(t, NotApplicable, checks["1.1.2"].State)
+}
+
+func TestSummarizer_regradeOverridden(t *testing.T) {
+	controlsDir := writeTestBenchmark(t,
+		testTarget{name: "master", checks: []string{"1.1.1", "1.1.2", "1.1.3"}},
+	)
+	inputDir := t.TempDir()
+	writeTestResults(t, inputDir, "m1", "master", map[string]kb.State{"1.1.1": kb.PASS, "1.1.2": kb.PASS, "1.1.3": kb.PASS})
+	writeTestResults(t, inputDir, "m2", "master", map[string]kb.State{"1.1.1": kb.PASS, "1.1.2": kb.PASS, "1.1.3": kb.PASS})
+	notApplicable := writeTestFile(t, "not-applicable.json", `{"1.1.1": "not applicable"}`)
+	defaultSkip := writeTestFile(t, "default-skip.json", `{"1.1.2": "skipped"}`)
+
+	s, err := NewSummarizer("", testBenchmark, controlsDir, inputDir, t.TempDir(), DefaultOutputFileName, "", defaultSkip, notApplicable, false)
+	require.Nil(t, err)
+	r := &recordingRegrader{controls: map[string][]kb.Check{}}
+	s.Regrader = r
+	checks := summarizeTest(t, s)
+
+	// the controls are the ones loaded, not the ones the first pass overrode
+	for _, id := range []string{"1.1.1", "1.1.2", "1.1.3"} {
+		require.Len(t, r.controls[id], 2, id)
+		for _, control := range r.controls[id] {
+			assert.Equal(t, kb.State(""), control.State, id)
+			assert.Empty(t, control.Remediation, id)
+		}
+	}
+	assert.Equal(t, NotApplicable, checks["1.1.1"].State)
+	assert.Equal(t, "not applicable", checks["1.1.1"].Remediation)
+	assert.Equal(t, Skip, checks["1.1.2"].State)
+	assert.Equal(t, "skipped", checks["1.1.2"].Remediation)
+	assert.Equal(t, Fail, checks["1.1.3"].State)
+}
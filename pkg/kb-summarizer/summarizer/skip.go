@@ -0,0 +1,380 @@
+package summarizer
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// expiryDateLayout is the layout of the expiry dates of the exceptions, which
+// apply until the end of that day (UTC).
+const expiryDateLayout = "2006-01-02"
+
+// Scope restricts a skip or not applicable entry to some nodes. An empty
+// scope applies to all the nodes.
+type Scope struct {
+	// Nodes are patterns matching the names of the nodes.
+	Nodes []string `json:"nodes,omitempty"`
+	// NodeTypes are the etcd, master, node or cluster node types.
+	NodeTypes []string `json:"node_types,omitempty"`
+}
+
+// IsEmpty reports whether the scope applies to all the nodes.
+func (sc Scope) IsEmpty() bool {
+	return len(sc.Nodes) == 0 && len(sc.NodeTypes) == 0
+}
+
+func (sc Scope) validate() error {
+	for _, pattern := range sc.Nodes {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid node pattern %q: %w", pattern, err)
+		}
+	}
+	for _, name := range sc.NodeTypes {
+		if _, ok := nodeTypeNames[name]; !ok {
+			return fmt.Errorf("unknown node type %q", name)
+		}
+	}
+	return nil
+}
+
+// AppliesTo reports whether the scope applies to the node, which ran a target
+// of the node type. A node matching either a pattern or a node type is in
+// scope.
+func (sc Scope) AppliesTo(hostname string, nodeType NodeType) bool {
+	if sc.IsEmpty() {
+		return true
+	}
+	for _, pattern := range sc.Nodes {
+		if ok, _ := path.Match(pattern, hostname); ok {
+			return true
+		}
+	}
+	for _, name := range sc.NodeTypes {
+		if nodeTypeNames[name] == nodeType {
+			return true
+		}
+	}
+	return false
+}
+
+// Rule is an entry of the default skip and not applicable configs, mapping
+// the ID pattern of the checks either to the reason, or to the reason and the scope:
+//
+//	{"1.1.1": "reason", "4.2.1": {"reason": "...", "nodes": ["gpu-*"]}}
+type Rule struct {
+	Reason string `json:"reason"`
+	Scope
+}
+
+func (r *Rule) UnmarshalJSON(data []byte) error {
+	var reason string
+	if err := json.Unmarshal(data, &reason); err == nil {
+		*r = Rule{Reason: reason}
+		return nil
+	}
+	type rule Rule
+	var v rule
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*r = Rule(v)
+	return nil
+}
+
+type skipConfig struct {
+	Skip map[string][]*Exception `json:"skip"`
+}
+
+// Exception is an entry of the user skip config. It is either the ID pattern
+// of the checks, or an object documenting why, by whom and until when the check is
+// skipped:
+//
+//	{"skip": {"rke2-cis-1.12": [
+//	  "1.1.1", "5.2.*",
+//	  {"id": "1.2.3", "justification": "...", "owner": "platform-team",
+//	   "ticket": "SEC-42", "expires": "2026-12-31", "nodes": ["worker-*"]}
+//	]}}
+type Exception struct {
+	ID            string `json:"id"`
+	Justification string `json:"justification,omitempty"`
+	Owner         string `json:"owner,omitempty"`
+	Ticket        string `json:"ticket,omitempty"`
+	// Expires is the last day the exception applies, as YYYY-MM-DD, or an
+	// RFC 3339 timestamp. The exception never expires when empty.
+	Expires string `json:"expires,omitempty"`
+	Scope
+
+	expiry time.Time
+}
+
+func (e *Exception) UnmarshalJSON(data []byte) error {
+	var id string
+	if err := json.Unmarshal(data, &id); err == nil {
+		*e = Exception{ID: id}
+		return nil
+	}
+	// the alias drops the methods of Exception, avoiding the recursion
+	type exception Exception
+	var v exception
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*e = Exception(v)
+	return nil
+}
+
+// parseExpiry validates the exception and parses its expiry.
+func (e *Exception) parseExpiry() error {
+	if e.ID == "" {
+		return fmt.Errorf("exception without a check id")
+	}
+	if err := e.Scope.validate(); err != nil {
+		return fmt.Errorf("invalid exception for check %v: %w", e.ID, err)
+	}
+	if e.Expires == "" {
+		return nil
+	}
+	if t, err := time.Parse(expiryDateLayout, e.Expires); err == nil {
+		e.expiry = t.AddDate(0, 0, 1)
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, e.Expires)
+	if err != nil {
+		return fmt.Errorf("invalid expiry %q of the exception for check %v, expected YYYY-MM-DD or RFC 3339", e.Expires, e.ID)
+	}
+	e.expiry = t
+	return nil
+}
+
+// Expired reports whether the exception no longer applies at now.
+func (e *Exception) Expired(now time.Time) bool {
+	return !e.expiry.IsZero() && !now.Before(e.expiry)
+}
+
+// Reason is the provenance reason of the checks skipped by the exception.
+func (e *Exception) Reason() string {
+	if e.Justification == "" {
+		return UserSkipReason
+	}
+	return e.Justification
+}
+
+// warning describes the expired exception for the report.
+func (e *Exception) warning() string {
+	var details []string
+	if e.Owner != "" {
+		details = append(details, "owner: "+e.Owner)
+	}
+	if e.Ticket != "" {
+		details = append(details, "ticket: "+e.Ticket)
+	}
+	msg := fmt.Sprintf("user skip exception for check %v expired on %v, reporting its actual state", e.ID, e.Expires)
+	if len(details) > 0 {
+		msg += " (" + strings.Join(details, ", ") + ")"
+	}
+	return msg
+}
+
+// GetUserSkipInfo returns the exceptions of the user skip config for the
+// benchmark, by check ID in the order of the config, falling back to the ones
+// of the current benchmark, then to the ones of a previous benchmark
+// translated with the crosswalk files of controlsDir. A check ID can have
+// several exceptions, e.g. with different node scopes.
+func GetUserSkipInfo(controlsDir, benchmark, skipConfigFile string) (map[string][]*Exception, error) {
+	skipMap := map[string][]*Exception{}
+	sc := &skipConfig{}
+	if skipConfigFile == "" {
+		return skipMap, nil
+	}
+	skipConfigFile = filepath.Clean(skipConfigFile)
+	data, err := os.ReadFile(skipConfigFile)
+	if err != nil {
+		return skipMap, fmt.Errorf("error reading file %v: %v", skipConfigFile, err)
+	}
+	err = json.Unmarshal(data, sc)
+	if err != nil {
+		return skipMap, fmt.Errorf("error unmarshalling skip str: %w", err)
+	}
+	skipArr, ok := sc.Skip[benchmark]
+	if !ok {
+		skipArr, ok = sc.Skip[CurrentBenchmarkKey]
+	}
+	if !ok {
+		skipArr, err = translatedExceptions(controlsDir, benchmark, sc)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, e := range skipArr {
+		if e == nil {
+			continue
+		}
+		if err := e.parseExpiry(); err != nil {
+			return nil, err
+		}
+		skipMap[e.ID] = append(skipMap[e.ID], e)
+	}
+	if _, err := parseIDPatterns(skipMap); err != nil {
+		return nil, err
+	}
+	slog.Debug("skipMap", "data", skipMap)
+	return skipMap, nil
+}
+
+// GetChecksMapFromConfigFile returns the rules of a default skip or not
+// applicable config, by check ID.
+func GetChecksMapFromConfigFile(configFile string) (map[string]*Rule, error) {
+	checksMap := map[string]*Rule{}
+	if configFile == "" {
+		return checksMap, nil
+	}
+	configFile = filepath.Clean(configFile)
+	slog.Info("loading checks from config file", "path", configFile)
+	data, err := os.ReadFile(configFile)
+	if err != nil {
+		return checksMap, fmt.Errorf("error reading file %v: %v", configFile, err)
+	}
+	if len(data) == 0 {
+		return checksMap, nil
+	}
+	if err := json.Unmarshal(data, &checksMap); err != nil {
+		return nil, fmt.Errorf("error unmarshalling config file %v: %v", configFile, err)
+	}
+	for id, r := range checksMap {
+		if r == nil {
+			delete(checksMap, id)
+			continue
+		}
+		if err := r.Scope.validate(); err != nil {
+			return nil, fmt.Errorf("invalid entry for check %v in config file %v: %w", id, configFile, err)
+		}
+	}
+	if _, err := parseIDPatterns(checksMap); err != nil {
+		return nil, fmt.Errorf("invalid config file %v: %w", configFile, err)
+	}
+	return checksMap, nil
+}
+
+// translatedExceptions returns the exceptions of the closest previous
+// benchmark of the user skip config with a crosswalk to the benchmark.
+func translatedExceptions(controlsDir, benchmark string, sc *skipConfig) ([]*Exception, error) {
+	var closest *Crosswalk
+	for _, from := range keys(sc.Skip) {
+		c, err := FindCrosswalk(controlsDir, from, benchmark)
+		if err != nil {
+			return nil, err
+		}
+		if c == nil {
+			continue
+		}
+		if closest == nil || len(c.steps) < len(closest.steps) || (len(c.steps) == len(closest.steps) && c.From > closest.From) {
+			closest = c
+		}
+	}
+	if closest == nil {
+		return nil, nil
+	}
+	slog.Info("translating the user skip config", "from", closest.From, "to", benchmark)
+	return closest.TranslateExceptions(sc.Skip[closest.From]), nil
+}
+
+// activeExceptions drops the exceptions expired at now, returning a warning
+// for each of them.
+func activeExceptions(exceptions map[string][]*Exception, now time.Time) (map[string][]*Exception, []string) {
+	active := map[string][]*Exception{}
+	var warnings []string
+	for id, es := range exceptions {
+		for _, e := range es {
+			if e.Expired(now) {
+				slog.Warn("user skip exception expired", "id", id, "expires", e.Expires, "owner", e.Owner, "ticket", e.Ticket)
+				warnings = append(warnings, e.warning())
+				continue
+			}
+			active[id] = append(active[id], e)
+		}
+	}
+	sort.Strings(warnings)
+	return active, warnings
+}
+
+// userException returns the first exception of the user skip config for the
+// ID in scope, those of the ID first, nil when there is none.
+func (s *Summarizer) userException(id string, inScope func(Scope) bool) *Exception {
+	for _, exceptions := range lookupEntries(s.userSkip, s.userSkipPatterns, id) {
+		for _, e := range exceptions {
+			if inScope(e.Scope) {
+				return e
+			}
+		}
+	}
+	return nil
+}
+
+// skipConfigNames name the skip configs in the warnings.
+var skipConfigNames = map[Provenance]string{
+	ProvenanceNotApplicable: "not applicable config",
+	ProvenanceDefaultSkip:   "default skip config",
+	ProvenanceUserSkip:      "user skip config",
+}
+
+// unknownIDs warns about the IDs of a skip config that are not part of the
+// benchmark, and about its patterns matching none of its checks.
+func (s *Summarizer) unknownIDs(config Provenance, ids []string) []string {
+	var warnings []string
+	for _, id := range ids {
+		p, err := ParseIDPattern(id)
+		if err != nil {
+			continue
+		}
+		if p.IsExact() {
+			if _, ok := s.checkWrappersMaps[id]; !ok {
+				warnings = append(warnings, fmt.Sprintf("%v: check %v is not part of benchmark %v", skipConfigNames[config], id, s.BenchmarkVersion))
+			}
+			continue
+		}
+		if len(s.expandPatterns(config, []*IDPattern{p})[0].Checks) == 0 {
+			warnings = append(warnings, fmt.Sprintf("%v: pattern %v matches no check of benchmark %v", skipConfigNames[config], id, s.BenchmarkVersion))
+		}
+	}
+	sort.Strings(warnings)
+	return warnings
+}
+
+// validateSkipConfigs cross-checks the skip configs against the checks of the
+// benchmark.
+func (s *Summarizer) validateSkipConfigs() []string {
+	var warnings []string
+	warnings = append(warnings, s.unknownIDs(ProvenanceNotApplicable, keys(s.notApplicable))...)
+	warnings = append(warnings, s.unknownIDs(ProvenanceDefaultSkip, keys(s.defaultSkip))...)
+	warnings = append(warnings, s.unknownIDs(ProvenanceUserSkip, keys(s.userSkip))...)
+	for _, w := range warnings {
+		slog.Warn("invalid skip config entry", "warning", w)
+	}
+	return warnings
+}
+
+// CheckSkipConfigs fails when the skip configs name checks that are not part
+// of the benchmark, for the strict mode.
+func (s *Summarizer) CheckSkipConfigs() error {
+	if len(s.unknownSkipIDs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("the skip configs do not match benchmark %v:\n%v", s.BenchmarkVersion, strings.Join(s.unknownSkipIDs, "\n"))
+}
+
+// keys returns the keys of the map, sorted.
+func keys[T any](m map[string]T) []string {
+	ks := make([]string, 0, len(m))
+	for k := range m {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+	return ks
+}
@@ -0,0 +1,206 @@
+package summarizer
+
+import (
+	"testing"
+	"time"
+
+	kb "github.com/aquasecurity/kube-bench/check"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetUserSkipInfo(t *testing.T) {
+	config := writeTestFile(t, "config.json", `{"skip": {
+		"`+testBenchmark+`": ["1.1.1", {"id": "1.1.2", "justification": "managed", "owner": "platform", "ticket": "SEC-1", "expires": "2026-06-30", "nodes": ["w*"]}],
+		"current": ["2.1.1"]
+	}}`)
+	exceptions, err := GetUserSkipInfo(t.TempDir(), testBenchmark, config)
+	require.Nil(t, err)
+	require.Len(t, exceptions, 2)
+	assert.Equal(t, "1.1.1", exceptions["1.1.1"][0].ID)
+	assert.Equal(t, UserSkipReason, exceptions["1.1.1"][0].Reason())
+	assert.False(t, exceptions["1.1.1"][0].Expired(time.Now()))
+
+	e := exceptions["1.1.2"][0]
+	assert.Equal(t, "managed", e.Reason())
+	assert.Equal(t, "platform", e.Owner)
+	assert.Equal(t, "SEC-1", e.Ticket)
+	// the exception applies until the end of its expiry day
+	assert.False(t, e.Expired(time.Date(2026, 6, 30, 23, 59, 0, 0, time.UTC)))
+	assert.True(t, e.Expired(time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)))
+	assert.True(t, e.AppliesTo("w1", NodeTypeNode))
+	assert.False(t, e.AppliesTo("m1", NodeTypeNode))
+
+	// the current benchmark is the fallback
+	exceptions, err = GetUserSkipInfo(t.TempDir(), "other", config)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"2.1.1"}, ids(exceptions))
+
+	// the exceptions of a check are kept in order
+	exceptions, err = GetUserSkipInfo(t.TempDir(), testBenchmark, writeTestFile(t, "config.json", `{"skip": {"`+testBenchmark+`": [
+		{"id": "1.1.1", "ticket": "SEC-1", "nodes": ["m*"]}, {"id": "1.1.1", "ticket": "SEC-2", "nodes": ["w*"]}
+	]}}`))
+	require.Nil(t, err)
+	require.Len(t, exceptions["1.1.1"], 2)
+	assert.Equal(t, "SEC-1", exceptions["1.1.1"][0].Ticket)
+	assert.Equal(t, "SEC-2", exceptions["1.1.1"][1].Ticket)
+
+	for _, invalid := range []string{
+		`{"skip": {"current": [{"id": "1.1.1", "expires": "tomorrow"}]}}`,
+		`{"skip": {"current": [{"justification": "no id"}]}}`,
+		`{"skip": {"current": [1]}}`,
+		`{"skip": {"current": [{"id": "1.1.1", "node_types": ["gpu"]}]}}`,
+	} {
+		_, err = GetUserSkipInfo(t.TempDir(), testBenchmark, writeTestFile(t, "config.json", invalid))
+		assert.NotNil(t, err, invalid)
+	}
+}
+
+func ids(exceptions map[string][]*Exception) []string {
+	var ids []string
+	for id := range exceptions {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func TestSummarizer_exceptions(t *testing.T) {
+	controlsDir := writeTestBenchmark(t, testTarget{name: "node", checks: []string{"4.1.1", "4.1.2", "4.1.3", "4.1.4", "4.1.5"}})
+	inputDir := t.TempDir()
+	for _, host := range []string{"m1", "w1", "w2"} {
+		writeTestResults(t, inputDir, host, "node", map[string]kb.State{"4.1.1": kb.FAIL, "4.1.2": kb.FAIL, "4.1.3": kb.FAIL, "4.1.4": kb.FAIL, "4.1.5": kb.FAIL})
+	}
+	userSkip := writeTestFile(t, "user-skip.json", `{"skip": {"`+testBenchmark+`": [
+		{"id": "4.1.1", "justification": "accepted risk", "owner": "platform", "expires": "2999-12-31"},
+		{"id": "4.1.2", "owner": "platform", "ticket": "SEC-2", "expires": "2001-01-01"},
+		{"id": "4.1.3", "justification": "workers only", "nodes": ["w*"]},
+		{"id": "4.1.4", "justification": "masters", "ticket": "SEC-3", "nodes": ["m*"]},
+		{"id": "4.1.4", "justification": "workers", "ticket": "SEC-4", "nodes": ["w*"]},
+		{"id": "4.1.5", "ticket": "SEC-5", "expires": "2001-01-01"},
+		{"id": "4.1.5", "ticket": "SEC-6", "expires": "2999-12-31"}
+	]}}`)
+
+	s, err := NewSummarizer("", testBenchmark, controlsDir, inputDir, t.TempDir(), DefaultOutputFileName, userSkip, "", "", false)
+	require.Nil(t, err)
+	checks := summarizeTest(t, s)
+
+	assert.Equal(t, Skip, checks["4.1.1"].State)
+	assert.Equal(t, "accepted risk", checks["4.1.1"].ProvenanceReason)
+	assert.Equal(t, "platform", checks["4.1.1"].Exception.Owner)
+
+	// the expired exception reverts to the actual state, with a warning
+	assert.Equal(t, Fail, checks["4.1.2"].State)
+	assert.Equal(t, ProvenanceNone, checks["4.1.2"].Provenance)
+	assert.Nil(t, checks["4.1.2"].Exception)
+	assert.Equal(t, []string{
+		"user skip exception for check 4.1.2 expired on 2001-01-01, reporting its actual state (owner: platform, ticket: SEC-2)",
+		"user skip exception for check 4.1.5 expired on 2001-01-01, reporting its actual state (ticket: SEC-5)",
+	}, s.fullReport.ConfigWarnings)
+
+	// the exception only skips the workers, the check fails on the others
+	assert.Equal(t, Fail, checks["4.1.3"].State)
+	assert.Equal(t, ProvenanceUserSkip, checks["4.1.3"].Provenance)
+
+	// each exception of a check skips the nodes in its scope
+	assert.Equal(t, Skip, checks["4.1.4"].State)
+
+	// the active exception applies when another one of the check expired
+	assert.Equal(t, Skip, checks["4.1.5"].State)
+	assert.Equal(t, "SEC-6", checks["4.1.5"].Exception.Ticket)
+}
+
+func TestGetChecksMapFromConfigFile(t *testing.T) {
+	rules, err := GetChecksMapFromConfigFile(writeTestFile(t, "config.json",
+		`{"1.1.1": "not used", "4.2.1": {"reason": "gpu pool", "nodes": ["gpu-*"], "node_types": ["etcd"]}}`))
+	require.Nil(t, err)
+	assert.Equal(t, &Rule{Reason: "not used"}, rules["1.1.1"])
+	assert.True(t, rules["1.1.1"].AppliesTo("m1", NodeTypeMaster))
+	r := rules["4.2.1"]
+	assert.Equal(t, "gpu pool", r.Reason)
+	assert.True(t, r.AppliesTo("gpu-1", NodeTypeNode))
+	assert.True(t, r.AppliesTo("m1", NodeTypeEtcd))
+	assert.False(t, r.AppliesTo("w1", NodeTypeNode))
+
+	for _, invalid := range []string{
+		`{"1.1.1": {"reason": "x", "node_types": ["gpu"]}}`,
+		`{"1.1.1": {"reason": "x", "nodes": ["["]}}`,
+		`{"1.1.1": 1}`,
+	} {
+		_, err = GetChecksMapFromConfigFile(writeTestFile(t, "config.json", invalid))
+		assert.NotNil(t, err, invalid)
+	}
+}
+
+func TestSummarizer_scopedRules(t *testing.T) {
+	controlsDir := writeTestBenchmark(t,
+		testTarget{name: "master", checks: []string{"1.1.1"}},
+		testTarget{name: "node", checks: []string{"4.2.1", "4.2.2", "4.2.3", "4.2.4"}},
+	)
+	inputDir := t.TempDir()
+	writeTestResults(t, inputDir, "m1", "master", map[string]kb.State{"1.1.1": kb.FAIL})
+	writeTestResults(t, inputDir, "m1", "node", map[string]kb.State{"4.2.1": kb.PASS, "4.2.2": kb.PASS, "4.2.3": kb.PASS, "4.2.4": kb.FAIL})
+	writeTestResults(t, inputDir, "w1", "node", map[string]kb.State{"4.2.1": kb.PASS, "4.2.2": kb.FAIL, "4.2.3": kb.PASS, "4.2.4": kb.FAIL})
+	writeTestResults(t, inputDir, "gpu-1", "node", map[string]kb.State{"4.2.1": kb.FAIL, "4.2.2": kb.FAIL, "4.2.3": kb.FAIL, "4.2.4": kb.FAIL})
+	notApplicable := writeTestFile(t, "not-applicable.json", `{
+		"4.2.1": {"reason": "gpu pool", "nodes": ["gpu-*"]},
+		"4.2.2": {"reason": "gpu pool", "nodes": ["gpu-*"]}
+	}`)
+	userSkip := writeTestFile(t, "user-skip.json", `{"skip": {"`+testBenchmark+`": [
+		{"id": "4.2.4", "justification": "workers", "ticket": "SEC-1", "nodes": ["w*"]},
+		{"id": "4.2.4", "justification": "gpu pool", "ticket": "SEC-2", "nodes": ["gpu-*"]}
+	]}}`)
+	defaultSkip := writeTestFile(t, "default-skip.json", `{
+		"1.1.1": {"reason": "managed masters", "node_types": ["master"]},
+		"4.2.3": {"reason": "managed masters", "node_types": ["master"]}
+	}`)
+
+	s, err := NewSummarizer("", testBenchmark, controlsDir, inputDir, t.TempDir(), DefaultOutputFileName, userSkip, defaultSkip, notApplicable, false)
+	require.Nil(t, err)
+	checks := summarizeTest(t, s)
+
+	// the other hosts pass
+	assert.Equal(t, Pass, checks["4.2.1"].State)
+	assert.Empty(t, checks["4.2.1"].Nodes)
+	assert.Equal(t, ProvenanceNotApplicable, checks["4.2.1"].Provenance)
+	assert.Equal(t, "gpu pool", checks["4.2.1"].ProvenanceReason)
+	assert.NotEqual(t, "gpu pool", checks["4.2.1"].Remediation)
+	// the other hosts disagree, only the failing one is listed
+	assert.Equal(t, Mixed, checks["4.2.2"].State)
+	assert.Equal(t, []string{"w1"}, checks["4.2.2"].Nodes)
+	// the state of each host is kept for the report
+	assert.Equal(t, map[string]kb.State{"m1": kb.PASS, "w1": kb.FAIL, "gpu-1": NA}, checks["4.2.2"].StateNodeMap)
+	// the entry covers all the hosts of the check
+	assert.Equal(t, Skip, checks["1.1.1"].State)
+	assert.Equal(t, ProvenanceDefaultSkip, checks["1.1.1"].Provenance)
+	// the node type of the node target is not in scope
+	assert.Equal(t, Mixed, checks["4.2.3"].State)
+	assert.Equal(t, []string{"gpu-1"}, checks["4.2.3"].Nodes)
+	assert.Equal(t, ProvenanceNone, checks["4.2.3"].Provenance)
+	// the override of the check is the one of the first overridden host,
+	// whatever the order the hosts are read in
+	assert.Equal(t, Fail, checks["4.2.4"].State)
+	assert.Equal(t, ProvenanceUserSkip, checks["4.2.4"].Provenance)
+	assert.Equal(t, "SEC-2", checks["4.2.4"].Exception.Ticket)
+	assert.Equal(t, override{}, s.overrides["4.2.4"]["m1"], "the host out of scope is not overridden")
+	assert.Equal(t, "SEC-1", s.overrides["4.2.4"]["w1"].exception.Ticket)
+}
+
+func TestSummarizer_validateSkipConfigs(t *testing.T) {
+	controlsDir := writeTestBenchmark(t, testTarget{name: "master", checks: []string{"1.1.1", "1.1.2"}})
+	userSkip := writeTestFile(t, "user-skip.json", `{"skip": {"`+testBenchmark+`": ["1.1.1", "1.1.9", "2.*"]}}`)
+	notApplicable := writeTestFile(t, "not-applicable.json", `{"1.1.2": "known", "1.2.1": "renumbered"}`)
+
+	s, err := NewSummarizer("", testBenchmark, controlsDir, t.TempDir(), t.TempDir(), DefaultOutputFileName, userSkip, "", notApplicable, false)
+	require.Nil(t, err)
+	assert.Equal(t, []string{
+		"not applicable config: check 1.2.1 is not part of benchmark " + testBenchmark,
+		"user skip config: check 1.1.9 is not part of benchmark " + testBenchmark,
+		"user skip config: pattern 2.* matches no check of benchmark " + testBenchmark,
+	}, s.fullReport.ConfigWarnings)
+	assert.NotNil(t, s.CheckSkipConfigs())
+
+	s, err = NewSummarizer("", testBenchmark, controlsDir, t.TempDir(), t.TempDir(), DefaultOutputFileName, "", "", "", false)
+	require.Nil(t, err)
+	assert.Empty(t, s.fullReport.ConfigWarnings)
+	assert.Nil(t, s.CheckSkipConfigs())
+}
@@ -12,6 +12,8 @@ import (
 	"os/exec"
 	"path/filepath"
 	"sort"
+	"strings"
+	"time"
 
 	kb "github.com/aquasecurity/kube-bench/check"
 	"github.com/spf13/viper"
@@ -23,6 +25,7 @@ const (
 	DefaultControlsDirectory    = "/etc/kube-bench/cfg"
 	VersionMappingKey           = "version_mapping"
 	TargetMappingKey            = "target_mapping"
+	TargetNodeTypeMappingKey    = "target_node_type_mapping"
 	ConfigFilename              = "config.yaml"
 	MasterControlsFilename      = "master.yaml"
 	EtcdControlsFilename        = "etcd.yaml"
@@ -38,21 +41,45 @@ const (
 
 type Summarizer struct {
 	// mapping for k8s version to default benchmark version
-	kubeToBenchmarkMap   map[string]string
-	BenchmarkVersion     string
-	ControlsDirectory    string
-	InputDirectory       string
-	OutputDirectory      string
-	OutputFilename       string
-	FailuresOnly         bool
-	fullReport           *SummarizedReport
-	groupWrappersMap     map[string]*GroupWrapper
-	checkWrappersMaps    map[string]*CheckWrapper
-	userSkip             map[string]bool
-	defaultSkip          map[string]string
-	notApplicable        map[string]string
+	kubeToBenchmarkMap map[string]string
+	BenchmarkVersion   string
+	ControlsDirectory  string
+	InputDirectory     string
+	OutputDirectory    string
+	OutputFilename     string
+	FailuresOnly       bool
+	fullReport         *SummarizedReport
+	groupWrappersMap   map[string]*GroupWrapper
+	checkWrappersMaps  map[string]*CheckWrapper
+	userSkip           map[string][]*Exception
+	defaultSkip        map[string]*Rule
+	notApplicable      map[string]*Rule
+	// the patterns of the skip configs other than single IDs
+	userSkipPatterns      []*IDPattern
+	defaultSkipPatterns   []*IDPattern
+	notApplicablePatterns []*IDPattern
+	// unknownSkipIDs warn about the entries of the skip configs that are not
+	// part of the benchmark.
+	unknownSkipIDs []string
+	// scopedNodes are the hosts of each check whose state a node scoped
+	// entry of the skip configs overrode.
+	scopedNodes map[string]map[string]bool
+	// overrides are the overrides of the state of each check on each host,
+	// none for the hosts whose state was not overridden.
+	overrides            map[string]map[string]override
 	nodeSeen             map[NodeType]map[string]bool
 	BenchmarkToConfigMap map[string][]string
+	// TargetToNodeTypeMap maps the targets to the node type their results
+	// belong to.
+	TargetToNodeTypeMap map[string]NodeType
+	// Regrader, when set, grades the results of the input directory again
+	// against the controls of the benchmark.
+	Regrader Regrader
+	// controls are the checks of the benchmark by target and ID.
+	controls map[string]map[string]*kb.Check
+	// notRegradeable are the reasons the results of checks were kept instead
+	// of regraded.
+	notRegradeable map[string]string
 }
 
 type State string
@@ -71,6 +98,20 @@ const (
 	CheckTypeSkip = "skip"
 )
 
+// Provenance records which configuration overrode the state of a check.
+type Provenance string
+
+const (
+	ProvenanceNone          Provenance = ""
+	ProvenanceBenchmark     Provenance = "b"
+	ProvenanceNotApplicable Provenance = "na"
+	ProvenanceDefaultSkip   Provenance = "ds"
+	ProvenanceUserSkip      Provenance = "us"
+
+	BenchmarkSkipReason = "marked as not applicable by the benchmark"
+	UserSkipReason      = "skipped by the user skip config"
+)
+
 type NodeType string
 
 const (
@@ -78,15 +119,40 @@ const (
 	NodeTypeEtcd   NodeType = "e"
 	NodeTypeMaster NodeType = "m"
 	NodeTypeNode   NodeType = "n"
+	// NodeTypeCluster is the type of the checks of the cluster as a whole,
+	// e.g. the controlplane and policies targets. They are evaluated once and
+	// reported against the single ClusterNodeName subject.
+	NodeTypeCluster NodeType = "c"
+
+	ClusterNodeName = "cluster"
 )
 
 const (
-	FilePathNodeTypeNone   string = ""
-	FilePathNodeTypeEtcd   string = "etcd"
-	FilePathNodeTypeMaster string = "master"
-	FilePathNodeTypeNode   string = "node"
+	FilePathNodeTypeNone    string = ""
+	FilePathNodeTypeEtcd    string = "etcd"
+	FilePathNodeTypeMaster  string = "master"
+	FilePathNodeTypeNode    string = "node"
+	FilePathNodeTypeCluster string = "cluster"
 )
 
+// nodeTypeNames are the node types accepted in the target node type mapping.
+var nodeTypeNames = map[string]NodeType{
+	FilePathNodeTypeEtcd:    NodeTypeEtcd,
+	FilePathNodeTypeMaster:  NodeTypeMaster,
+	FilePathNodeTypeNode:    NodeTypeNode,
+	FilePathNodeTypeCluster: NodeTypeCluster,
+}
+
+// defaultTargetToNodeTypeMap applies when config.yaml has no target node type
+// mapping. Targets missing from the mapping are cluster scoped.
+var defaultTargetToNodeTypeMap = map[string]NodeType{
+	FilePathNodeTypeEtcd:   NodeTypeEtcd,
+	FilePathNodeTypeMaster: NodeTypeMaster,
+	FilePathNodeTypeNode:   NodeTypeNode,
+	"controlplane":         NodeTypeCluster,
+	"policies":             NodeTypeCluster,
+}
+
 type CheckWrapper struct {
 	ID                 string                       `yaml:"id" json:"id"`
 	Text               string                       `json:"d"`
@@ -104,7 +170,19 @@ type CheckWrapper struct {
 	Commands           []*exec.Cmd                  `json:"c"`
 	ConfigCommands     []*exec.Cmd                  `json:"cc"`
 	ActualValueNodeMap map[string]string            `json:"avmap"`
-	ExpectedResult     string                       `json:"er"`
+	// StateNodeMap is the state of the check on each host, after the skip
+	// and not applicable overrides.
+	StateNodeMap     map[string]kb.State `json:"-"`
+	ExpectedResult   string              `json:"er"`
+	Provenance       Provenance          `json:"pv,omitempty"`
+	ProvenanceReason string              `json:"pvr,omitempty"`
+	Exception        *Exception          `json:"ex,omitempty"`
+	// NotRegradeable is the reason the results of the check were kept when
+	// regrading them.
+	NotRegradeable string `json:"nrg,omitempty"`
+	// HostStates is the state of a cluster check on each master that
+	// evaluated it, kept when they disagree.
+	HostStates map[string]kb.State `json:"hs,omitempty"`
 }
 
 type GroupWrapper struct {
@@ -125,6 +203,15 @@ type SummarizedReport struct {
 	GroupWrappers []*GroupWrapper       `json:"o"`
 	// ActualValueMapData is the base64-encoded gzipped-compressed avmap data of all checks.
 	ActualValueMapData string `json:"actual_value_map_data"`
+	// ConfigWarnings are the problems found in the skip configs, such as the
+	// expired exceptions or the checks that are not part of the benchmark.
+	ConfigWarnings []string `json:"cw,omitempty"`
+	// PatternExpansions are the checks each pattern of the skip configs
+	// matches.
+	PatternExpansions []*PatternExpansion `json:"pe,omitempty"`
+	// Regraded is set when the results were graded again against the
+	// current controls.
+	Regraded bool `json:"rg,omitempty"`
 }
 
 type ActualValueGroup struct {
@@ -134,13 +221,10 @@ type ActualValueGroup struct {
 }
 
 type ActualValueCheck struct {
-	ID                 string            `yaml:"id" json:"id"`
-	Text               string            `json:"description"`
-	ActualValueNodeMap map[string]string `json:"actual_value_node_map"`
-}
-
-type skipConfig struct {
-	Skip map[string][]string `json:"skip"`
+	ID                 string              `yaml:"id" json:"id"`
+	Text               string              `json:"description"`
+	ActualValueNodeMap map[string]string   `json:"actual_value_node_map"`
+	StateNodeMap       map[string]kb.State `json:"state_node_map,omitempty"`
 }
 
 func NewSummarizer(
@@ -169,6 +253,8 @@ func NewSummarizer(
 		groupWrappersMap:  map[string]*GroupWrapper{},
 		checkWrappersMaps: map[string]*CheckWrapper{},
 		nodeSeen:          map[NodeType]map[string]bool{},
+		scopedNodes:       map[string]map[string]bool{},
+		overrides:         map[string]map[string]override{},
 	}
 	if err := s.loadVersionMapping(); err != nil {
 		return nil, fmt.Errorf("error loading version mapping: %w", err)
@@ -178,6 +264,10 @@ func NewSummarizer(
 		return nil, fmt.Errorf("error loading target mapping: %w", err)
 	}
 
+	if err := s.loadTargetNodeTypeMapping(); err != nil {
+		return nil, fmt.Errorf("error loading target node type mapping: %w", err)
+	}
+
 	if benchmarkVersion != "" {
 		s.BenchmarkVersion = benchmarkVersion
 	} else {
@@ -187,11 +277,11 @@ func NewSummarizer(
 		}
 	}
 
-	userSkip, err := GetUserSkipInfo(s.BenchmarkVersion, userSkipConfigFile)
+	userSkip, err := GetUserSkipInfo(s.ControlsDirectory, s.BenchmarkVersion, userSkipConfigFile)
 	if err != nil {
 		return nil, fmt.Errorf("error getting user skip info: %w", err)
 	}
-	s.userSkip = userSkip
+	s.userSkip, s.fullReport.ConfigWarnings = activeExceptions(userSkip, time.Now())
 
 	defaultSkip, err := GetChecksMapFromConfigFile(defaultSkipConfigFile)
 	if err != nil {
@@ -205,59 +295,25 @@ func NewSummarizer(
 	}
 	s.notApplicable = notApplicable
 
-	if err := s.loadControls(); err != nil {
-		return nil, fmt.Errorf("error loading controls: %w", err)
-	}
-	return s, nil
-}
-
-func GetUserSkipInfo(benchmark, skipConfigFile string) (map[string]bool, error) {
-	skipMap := map[string]bool{}
-	sc := &skipConfig{}
-	if skipConfigFile == "" {
-		return skipMap, nil
+	if s.userSkipPatterns, err = parseIDPatterns(s.userSkip); err != nil {
+		return nil, fmt.Errorf("error parsing user skip info: %w", err)
 	}
-	skipConfigFile = filepath.Clean(skipConfigFile)
-	data, err := os.ReadFile(skipConfigFile)
-	if err != nil {
-		return skipMap, fmt.Errorf("error reading file %v: %v", skipConfigFile, err)
+	if s.defaultSkipPatterns, err = parseIDPatterns(s.defaultSkip); err != nil {
+		return nil, fmt.Errorf("error parsing default skip info: %w", err)
 	}
-	err = json.Unmarshal(data, sc)
-	if err != nil {
-		return skipMap, fmt.Errorf("error unmarshalling skip str: %w", err)
-	}
-	skipArr, ok := sc.Skip[benchmark]
-	if !ok {
-		skipArr = sc.Skip[CurrentBenchmarkKey]
+	if s.notApplicablePatterns, err = parseIDPatterns(s.notApplicable); err != nil {
+		return nil, fmt.Errorf("error parsing not applicable info: %w", err)
 	}
-	if len(skipArr) == 0 {
-		return skipMap, nil
-	}
-	for _, v := range skipArr {
-		skipMap[v] = true
-	}
-	slog.Debug("skipMap", "data", skipMap)
-	return skipMap, nil
-}
 
-func GetChecksMapFromConfigFile(configFile string) (map[string]string, error) {
-	checksMap := map[string]string{}
-	if configFile == "" {
-		return checksMap, nil
-	}
-	configFile = filepath.Clean(configFile)
-	slog.Info("loading checks from config file", "path", configFile)
-	data, err := os.ReadFile(configFile)
-	if err != nil {
-		return checksMap, fmt.Errorf("error reading file %v: %v", configFile, err)
-	}
-	if len(data) == 0 {
-		return checksMap, nil
-	}
-	if err := json.Unmarshal(data, &checksMap); err != nil {
-		return nil, fmt.Errorf("error unmarshalling config file %v: %v", configFile, err)
+	if err := s.loadControls(); err != nil {
+		return nil, fmt.Errorf("error loading controls: %w", err)
 	}
-	return checksMap, nil
+	s.fullReport.PatternExpansions = append(s.fullReport.PatternExpansions, s.expandPatterns(ProvenanceNotApplicable, s.notApplicablePatterns)...)
+	s.fullReport.PatternExpansions = append(s.fullReport.PatternExpansions, s.expandPatterns(ProvenanceDefaultSkip, s.defaultSkipPatterns)...)
+	s.fullReport.PatternExpansions = append(s.fullReport.PatternExpansions, s.expandPatterns(ProvenanceUserSkip, s.userSkipPatterns)...)
+	s.unknownSkipIDs = s.validateSkipConfigs()
+	s.fullReport.ConfigWarnings = append(s.fullReport.ConfigWarnings, s.unknownSkipIDs...)
+	return s, nil
 }
 
 func (s *Summarizer) getBenchmarkFor(k8sVersion string) (string, error) {
@@ -271,7 +327,7 @@ func (s *Summarizer) getBenchmarkFor(k8sVersion string) (string, error) {
 	return b, nil
 }
 
-func (s *Summarizer) processOneResultFileForHost(results *kb.Controls, hostname string) {
+func (s *Summarizer) processOneResultFileForHost(results *kb.Controls, hostname string, nodeType NodeType) {
 	for _, group := range results.Groups {
 		for _, check := range group.Checks {
 			slog.Info("process result file for host", "host", hostname, "id", check.ID, "state", check.State)
@@ -281,18 +337,17 @@ func (s *Summarizer) processOneResultFileForHost(results *kb.Controls, hostname
 				slog.Error("check found in results but not in spec", "checkID", check.ID)
 				continue
 			}
-			if check.Type == CheckTypeSkip {
-				check.State = NA
+			o := s.overrideState(check, hostname, nodeType)
+			if o.scoped {
+				if s.scopedNodes[check.ID] == nil {
+					s.scopedNodes[check.ID] = map[string]bool{}
+				}
+				s.scopedNodes[check.ID][hostname] = true
 			}
-			if msg, ok := s.notApplicable[check.ID]; ok {
-				check.State = NA
-				check.Remediation = msg
-			} else if msg, ok := s.defaultSkip[check.ID]; ok {
-				check.State = SKIP
-				check.Remediation = msg
-			} else if s.userSkip[check.ID] {
-				check.State = SKIP
+			if s.overrides[check.ID] == nil {
+				s.overrides[check.ID] = map[string]override{}
 			}
+			s.overrides[check.ID][hostname] = o
 			if cw.Result[check.State] == nil {
 				cw.Result[check.State] = make(map[string]bool)
 			}
@@ -302,19 +357,90 @@ func (s *Summarizer) processOneResultFileForHost(results *kb.Controls, hostname
 				cw.ActualValueNodeMap = make(map[string]string)
 			}
 			cw.ActualValueNodeMap[hostname] = check.ActualValue
+			if cw.StateNodeMap == nil {
+				cw.StateNodeMap = make(map[string]kb.State)
+			}
+			cw.StateNodeMap[hostname] = check.State
 
 			resultCheckWrapper := getCheckWrapper(check)
 			resultCheckWrapper.Result = cw.Result
 			resultCheckWrapper.ActualValueNodeMap = cw.ActualValueNodeMap
+			resultCheckWrapper.StateNodeMap = cw.StateNodeMap
 			s.checkWrappersMaps[check.ID] = resultCheckWrapper
 		}
 	}
 }
 
+// override is the outcome of overrideState.
+type override struct {
+	provenance Provenance
+	reason     string
+	exception  *Exception
+	// scoped is set when a node scoped entry applied.
+	scoped bool
+}
+
+// overrideState applies the benchmark "type: skip", then the user skip, the
+// default skip and the not applicable configs to the state of check, each
+// taking precedence over the previous ones. It returns which one applied, and
+// why. The entries apply to the results of a host of the node type when they
+// are in scope. When hostname is empty, only the default skip and not
+// applicable entries without a scope apply. Only those replace the
+// remediation, as the ones of a node scoped entry would depend on the host.
+func (s *Summarizer) overrideState(check *kb.Check, hostname string, nodeType NodeType) override {
+	var o override
+	inScope := func(sc Scope) bool {
+		if hostname == "" {
+			return sc.IsEmpty()
+		}
+		return sc.AppliesTo(hostname, nodeType)
+	}
+	if check.Type == CheckTypeSkip {
+		check.State = NA
+		o = override{provenance: ProvenanceBenchmark, reason: BenchmarkSkipReason}
+	}
+	ruleInScope := func(r *Rule) bool {
+		return inScope(r.Scope)
+	}
+	if r, ok := lookupEntry(s.notApplicable, s.notApplicablePatterns, check.ID, ruleInScope); ok {
+		check.State = NA
+		o = override{provenance: ProvenanceNotApplicable, reason: r.Reason, scoped: !r.IsEmpty()}
+	} else if r, ok := lookupEntry(s.defaultSkip, s.defaultSkipPatterns, check.ID, ruleInScope); ok {
+		check.State = SKIP
+		o = override{provenance: ProvenanceDefaultSkip, reason: r.Reason, scoped: !r.IsEmpty()}
+	} else if e := s.userException(check.ID, inScope); hostname != "" && e != nil {
+		check.State = SKIP
+		o = override{provenance: ProvenanceUserSkip, reason: e.Reason(), exception: e, scoped: !e.IsEmpty()}
+	}
+	if (o.provenance == ProvenanceNotApplicable || o.provenance == ProvenanceDefaultSkip) && !o.scoped {
+		check.Remediation = o.reason
+	}
+	return o
+}
+
+// checkOverride is the override of the state of the check, the one of the
+// first host, in name order, whose state was overridden. The hosts outside the
+// scope of a node scoped entry do not override it.
+func (s *Summarizer) checkOverride(id string) override {
+	hosts := s.overrides[id]
+	for _, host := range keys(hosts) {
+		if o := hosts[host]; o.provenance != ProvenanceNone {
+			return o
+		}
+	}
+	return override{}
+}
+
 func (s *Summarizer) addNode(nodeType NodeType, hostname string) {
 	if nodeType == NodeTypeNone {
 		return
 	}
+	if nodeType == NodeTypeCluster {
+		hostname = ClusterNodeName
+	}
+	if s.nodeSeen[nodeType] == nil {
+		s.nodeSeen[nodeType] = map[string]bool{}
+	}
 	if !s.nodeSeen[nodeType][hostname] {
 		s.nodeSeen[nodeType][hostname] = true
 		s.fullReport.Nodes[nodeType] = append(s.fullReport.Nodes[nodeType], hostname)
@@ -331,11 +457,9 @@ func (s *Summarizer) summarizeForHost(hostname string) error {
 		return fmt.Errorf("error globing files: %w", err)
 	}
 
-	nodeTypeMapping := getResultsFileNodeTypeMapping()
-
 	for _, resultFilePath := range resultFilesPaths {
 		resultFile := filepath.Base(resultFilePath)
-		nodeType, ok := nodeTypeMapping[resultFile]
+		nodeType, ok := s.getResultsFileNodeType(resultFile)
 		if !ok {
 			slog.Error("unknown result file found", "filePath", resultFilePath)
 			continue
@@ -355,7 +479,11 @@ func (s *Summarizer) summarizeForHost(hostname string) error {
 		}
 		slog.Debug("unmarshaled results", "data", results.Controls[0])
 
-		s.processOneResultFileForHost(results.Controls[0], hostname)
+		if s.Regrader != nil {
+			s.regrade(results.Controls[0], hostname, strings.TrimSuffix(resultFile, filepath.Ext(resultFile)))
+		}
+
+		s.processOneResultFileForHost(results.Controls[0], hostname, nodeType)
 	}
 	return nil
 }
@@ -428,6 +556,57 @@ func (s *Summarizer) loadTargetMapping() error {
 	return nil
 }
 
+func (s *Summarizer) loadTargetNodeTypeMapping() error {
+	configFileName := fmt.Sprintf("%s/%s", s.ControlsDirectory, ConfigFilename)
+	v := viper.New()
+	v.SetConfigFile(configFileName)
+	if err := v.ReadInConfig(); err != nil {
+		return fmt.Errorf("error reading in config file: %w", err)
+	}
+
+	targetToNodeTypeNames := v.GetStringMapString(TargetNodeTypeMappingKey)
+	if len(targetToNodeTypeNames) == 0 {
+		slog.Info("config file is missing the target node type mapping, using the defaults", "key", TargetNodeTypeMappingKey)
+		s.TargetToNodeTypeMap = defaultTargetToNodeTypeMap
+		return nil
+	}
+	targetToNodeTypeMap := map[string]NodeType{}
+	for target, name := range targetToNodeTypeNames {
+		nodeType, ok := nodeTypeNames[name]
+		if !ok {
+			return fmt.Errorf("unknown node type %q for target %v", name, target)
+		}
+		targetToNodeTypeMap[target] = nodeType
+	}
+	s.TargetToNodeTypeMap = targetToNodeTypeMap
+	slog.Info("Target node type mapping CONFIG", "map", targetToNodeTypeNames)
+	return nil
+}
+
+// getTargetNodeType returns the node type of a target, cluster when it is not
+// mapped.
+func (s *Summarizer) getTargetNodeType(target string) NodeType {
+	if nodeType, ok := s.TargetToNodeTypeMap[target]; ok {
+		return nodeType
+	}
+	return NodeTypeCluster
+}
+
+// getResultsFileNodeType returns the node type of a results file, named after
+// its target. Only the targets of the benchmark and the mapped ones are known.
+func (s *Summarizer) getResultsFileNodeType(resultFile string) (NodeType, bool) {
+	target := strings.TrimSuffix(resultFile, filepath.Ext(resultFile))
+	if _, ok := s.TargetToNodeTypeMap[target]; ok {
+		return s.getTargetNodeType(target), true
+	}
+	for _, t := range s.BenchmarkToConfigMap[s.BenchmarkVersion] {
+		if t == target {
+			return s.getTargetNodeType(target), true
+		}
+	}
+	return NodeTypeNone, false
+}
+
 func (s *Summarizer) loadControlsFromFile(filePath string) (*kb.Controls, error) {
 	controls := &kb.Controls{}
 	filePath = filepath.Clean(filePath)
@@ -442,59 +621,38 @@ func (s *Summarizer) loadControlsFromFile(filePath string) (*kb.Controls, error)
 	return controls, nil
 }
 
-func getResultsFileNodeTypeMapping() map[string]NodeType {
-	return map[string]NodeType{
-		MasterResultsFilename:       NodeTypeMaster,
-		EtcdResultsFilename:         NodeTypeEtcd,
-		NodeResultsFilename:         NodeTypeNode,
-		ControlPlaneResultsFilename: NodeTypeNone,
-		PoliciesResultsFilename:     NodeTypeNone,
-	}
-}
-
 func (s *Summarizer) getControlsFilePath(filename string) string {
 	return fmt.Sprintf("%s/%s/%s", s.ControlsDirectory, s.BenchmarkVersion, filename)
 }
 
-func (s *Summarizer) getNodeTypeControlsFileMapping() map[string]NodeType {
-	var filepaths = make(map[string]NodeType)
-	requiredFiles := s.BenchmarkToConfigMap[s.BenchmarkVersion]
-	for _, f := range requiredFiles {
-
-		if FilePathNodeTypeMaster == f {
-			FileName := s.getControlsFilePath(MasterControlsFilename)
-			filepaths[FileName] = NodeTypeMaster
-			continue
-		} else if FilePathNodeTypeEtcd == f {
-			FileName := s.getControlsFilePath(EtcdControlsFilename)
-			filepaths[FileName] = NodeTypeEtcd
-			continue
-		} else if FilePathNodeTypeNode == f {
-			FileName := s.getControlsFilePath(NodeControlsFilename)
-			filepaths[FileName] = NodeTypeNode
-			continue
-		} else {
-			FileName := s.getControlsFilePath(fmt.Sprintf("%s.yaml", f))
-			filepaths[FileName] = NodeTypeNone
-			continue
+// loadTargets loads the controls files of the targets of the benchmark, in
+// the order of the target mapping. The targets whose controls file can't be
+// loaded have no controls, and the error.
+func (s *Summarizer) loadTargets() []*Target {
+	var targets []*Target
+	for _, name := range s.BenchmarkToConfigMap[s.BenchmarkVersion] {
+		controlsFile := s.getControlsFilePath(fmt.Sprintf("%s.yaml", name))
+		controls, err := s.loadControlsFromFile(controlsFile)
+		if err != nil {
+			slog.Error("error loading controls from file", "path", controlsFile, "error", err)
 		}
+		targets = append(targets, &Target{Name: name, NodeType: s.getTargetNodeType(name), Controls: controls, Err: err})
 	}
-	return filepaths
+	return targets
 }
 
 func (s *Summarizer) loadControls() error {
 	var ok bool
-	controlsFiles := s.getNodeTypeControlsFileMapping()
-
 	var groupWrappers []*GroupWrapper
-	for controlsFile, nodeType := range controlsFiles {
-		s.nodeSeen[nodeType] = map[string]bool{}
-		controls, err := s.loadControlsFromFile(controlsFile)
-		if err != nil {
-			slog.Error("error loading controls from file %s: %v", controlsFile, err)
+	s.controls = map[string]map[string]*kb.Check{}
+	for _, target := range s.loadTargets() {
+		if target.Err != nil {
 			continue
 		}
-		for _, g := range controls.Groups {
+		nodeType := target.NodeType
+		s.nodeSeen[nodeType] = map[string]bool{}
+		s.controls[target.Name] = map[string]*kb.Check{}
+		for _, g := range target.Controls.Groups {
 			var gw *GroupWrapper
 			if gw, ok = s.groupWrappersMap[g.ID]; !ok {
 				gw = getGroupWrapper(g)
@@ -502,20 +660,18 @@ func (s *Summarizer) loadControls() error {
 				s.groupWrappersMap[g.ID] = gw
 			}
 			for _, check := range g.Checks {
-				if check.Type == CheckTypeSkip {
-					check.State = NA
-				}
-				if msg, ok := s.notApplicable[check.ID]; ok {
-					check.State = NA
-					check.Remediation = msg
-				} else if msg, ok := s.defaultSkip[check.ID]; ok {
-					check.State = SKIP
-					check.Remediation = msg
-				}
+				// overrideState changes the state and remediation of the
+				// check, the results are regraded against the control as
+				// loaded
+				control := *check
+				s.controls[target.Name][check.ID] = &control
+				o := s.overrideState(check, "", nodeType)
 				if cw, ok := s.checkWrappersMaps[check.ID]; !ok {
 					s.fullReport.Total++
 					c := getCheckWrapper(check)
 					c.NodeType = []NodeType{nodeType}
+					c.Provenance = o.provenance
+					c.ProvenanceReason = o.reason
 					gw.CheckWrappers = append(gw.CheckWrappers, c)
 					s.checkWrappersMaps[check.ID] = c
 				} else {
@@ -561,14 +717,8 @@ func getCheckWrapper(check *kb.Check) *CheckWrapper {
 }
 
 func (s *Summarizer) getNodesMapOfCheckWrapper(check *CheckWrapper) map[string]bool {
-	nodeTypeSlice := check.NodeType
-	// simple hack to get the count to match for empty node type
-	// TODO: Modify this when a new plugin of Job type is created
-	if len(nodeTypeSlice) == 1 && nodeTypeSlice[0] == NodeTypeNone {
-		nodeTypeSlice = []NodeType{NodeTypeMaster}
-	}
 	nodes := map[string]bool{}
-	for _, t := range nodeTypeSlice {
+	for _, t := range check.NodeType {
 		for _, v := range s.fullReport.Nodes[t] {
 			nodes[v] = true
 		}
@@ -586,6 +736,10 @@ func (s *Summarizer) getMissingNodesMapOfCheckWrapper(check *CheckWrapper, nodes
 	for n := range nodes {
 		delete(allNodes, n)
 	}
+	// the hosts a node scoped entry overrode did not fail the check
+	for n := range s.scopedNodes[check.ID] {
+		delete(allNodes, n)
+	}
 	slog.Debug("missing nodes", "ID", check.ID, "nodes", allNodes)
 	var missingNodes []string
 	for k := range allNodes {
@@ -600,10 +754,18 @@ func (s *Summarizer) getMissingNodesMapOfCheckWrapper(check *CheckWrapper, nodes
 //   - If a check has all pass, then nodes is empty. All nodes in that host type have passed.
 //   - If a check has all fail, then nodes is empty. All nodes in that host type have failed.
 //   - If a check is skipped, then nodes is empty.
+//   - The hosts a node scoped skip or not applicable entry overrode are left
+//     out, unless the entry covers all the hosts of the check.
+//   - The cluster checks have the single cluster subject, never mixed.
 func (s *Summarizer) runFinalPassOnCheckWrapper(cw *CheckWrapper) {
 	//copy over the actual result info of the test after running the scan
 	s.copyDataFromResults(cw)
+	excluded := s.excludeScopedNodes(cw)
+	collapseClusterResults(cw)
 	nodesMap := s.getNodesMapOfCheckWrapper(cw)
+	for n := range excluded {
+		delete(nodesMap, n)
+	}
 	nodeCount := len(nodesMap)
 	slog.Debug("final pass on check wrapper", "id", cw.ID, "nodeCount", nodeCount)
 	if len(cw.Result) == 1 {
@@ -681,6 +843,103 @@ func (s *Summarizer) runFinalPassOnCheckWrapper(cw *CheckWrapper) {
 	}
 }
 
+// excludeScopedNodes removes the hosts a node scoped entry overrode from the
+// results of the check, so that its state is the one of the other hosts. It
+// returns the removed hosts, none when the entry covers all of them.
+func (s *Summarizer) excludeScopedNodes(cw *CheckWrapper) map[string]bool {
+	scoped := s.scopedNodes[cw.ID]
+	if len(scoped) == 0 {
+		return nil
+	}
+	remaining := false
+	for _, hosts := range cw.Result {
+		for host := range hosts {
+			if !scoped[host] {
+				remaining = true
+			}
+		}
+	}
+	if !remaining {
+		return nil
+	}
+	for state, hosts := range cw.Result {
+		for host := range hosts {
+			if scoped[host] {
+				delete(hosts, host)
+			}
+		}
+		if len(hosts) == 0 {
+			delete(cw.Result, state)
+		}
+	}
+	return scoped
+}
+
+// isClusterScoped reports whether the check only belongs to cluster targets.
+func (cw *CheckWrapper) isClusterScoped() bool {
+	for _, t := range cw.NodeType {
+		if t != NodeTypeCluster {
+			return false
+		}
+	}
+	return len(cw.NodeType) > 0
+}
+
+// collapseClusterResults turns the results of a cluster scoped check, which
+// every master running the cluster targets reports, into a single result of
+// the cluster subject. When the masters disagree, the cluster gets the least
+// compliant of their states, and HostStates keeps the state of each of them.
+// The actual value is the one of the first master, in name order, with the
+// state of the cluster.
+func collapseClusterResults(cw *CheckWrapper) {
+	if !cw.isClusterScoped() || len(cw.Result) == 0 {
+		return
+	}
+	var state kb.State
+	for st := range cw.Result {
+		if state == "" || clusterStateRank(st) < clusterStateRank(state) {
+			state = st
+		}
+	}
+	if len(cw.Result) > 1 {
+		cw.HostStates = map[string]kb.State{}
+		for st, hosts := range cw.Result {
+			for host := range hosts {
+				cw.HostStates[host] = st
+			}
+		}
+	}
+	hosts := keys(cw.Result[state])
+	cw.Result = map[kb.State]map[string]bool{state: {ClusterNodeName: true}}
+	cw.StateNodeMap = map[string]kb.State{ClusterNodeName: state}
+	if cw.ActualValueNodeMap == nil {
+		return
+	}
+	value, ok := "", false
+	for _, host := range hosts {
+		if value, ok = cw.ActualValueNodeMap[host]; ok {
+			break
+		}
+	}
+	cw.ActualValueNodeMap = map[string]string{}
+	if ok {
+		cw.ActualValueNodeMap[ClusterNodeName] = value
+	}
+}
+
+// clusterStatePrecedence orders the states of the masters from the least
+// compliant, the unknown ones coming first.
+var clusterStatePrecedence = []kb.State{kb.FAIL, kb.WARN, kb.INFO, kb.PASS, SKIP, NA}
+
+func clusterStateRank(state kb.State) int {
+	for i, st := range clusterStatePrecedence {
+		if st == state {
+			return i
+		}
+	}
+	return -1
+}
+
 func (s *Summarizer) copyDataFromResults(cw *CheckWrapper) {
 	checkFromResults := s.checkWrappersMaps[cw.ID]
 	if checkFromResults == nil {
@@ -689,14 +948,24 @@ func (s *Summarizer) copyDataFromResults(cw *CheckWrapper) {
 	cw.Audit = checkFromResults.Audit
 	cw.AuditConfig = checkFromResults.AuditConfig
 	cw.ActualValueNodeMap = checkFromResults.ActualValueNodeMap
+	cw.StateNodeMap = checkFromResults.StateNodeMap
 	cw.ExpectedResult = checkFromResults.ExpectedResult
 	cw.Remediation = checkFromResults.Remediation
 	cw.TestInfo = checkFromResults.TestInfo
+	cw.Provenance = checkFromResults.Provenance
+	cw.ProvenanceReason = checkFromResults.ProvenanceReason
+	cw.Exception = checkFromResults.Exception
+	if _, ok := s.overrides[cw.ID]; ok {
+		o := s.checkOverride(cw.ID)
+		cw.Provenance, cw.ProvenanceReason, cw.Exception = o.provenance, o.reason, o.exception
+	}
+	cw.NotRegradeable = s.notRegradeable[cw.ID]
 }
 
 func (s *Summarizer) runFinalPass() error {
 	slog.Debug("running final pass")
 	s.fullReport.Version = s.BenchmarkVersion
+	s.fullReport.Regraded = s.Regrader != nil
 	groups := s.fullReport.GroupWrappers
 	for _, group := range groups {
 		for _, cw := range group.CheckWrappers {
@@ -823,6 +1092,30 @@ func (s *Summarizer) setFullReportActualValueMapData() error {
 	return nil
 }
 
+// DecodeActualValueMapData decodes the ActualValueMapData field of a report.
+func DecodeActualValueMapData(data string) ([]*ActualValueGroup, error) {
+	compressedData, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding base64 data: %w", err)
+	}
+	gzipReader, err := gzip.NewReader(bytes.NewReader(compressedData))
+	if err != nil {
+		return nil, fmt.Errorf("error creating gzip reader: %w", err)
+	}
+	jsonData, err := io.ReadAll(gzipReader)
+	if err != nil {
+		return nil, fmt.Errorf("error reading compressed data: %w", err)
+	}
+	if err := gzipReader.Close(); err != nil {
+		return nil, fmt.Errorf("error closing gzip reader: %w", err)
+	}
+	var avgroups []*ActualValueGroup
+	if err := json.Unmarshal(jsonData, &avgroups); err != nil {
+		return nil, fmt.Errorf("error decoding avgroups: %w", err)
+	}
+	return avgroups, nil
+}
+
 func mapGroupWrappersToActualValueGroups(grpWrappers []*GroupWrapper) []*ActualValueGroup {
 	avgroups := make([]*ActualValueGroup, len(grpWrappers))
 
@@ -835,6 +1128,7 @@ func mapGroupWrappersToActualValueGroups(grpWrappers []*GroupWrapper) []*ActualV
 				ID:                 cw.ID,
 				Text:               cw.Text,
 				ActualValueNodeMap: make(map[string]string, len(cw.ActualValueNodeMap)),
+				StateNodeMap:       cw.StateNodeMap,
 			}
 
 			for k, v := range cw.ActualValueNodeMap {
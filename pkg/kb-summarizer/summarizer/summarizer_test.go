@@ -7,8 +7,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
 
+	kb "github.com/aquasecurity/kube-bench/check"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -72,6 +77,10 @@ func TestSummarizer_handleAvMapData(t *testing.T) {
 	// verify ActualValueMapData
 	require.Equal(t, string(expectedAvGroupsJSON), string(avgroupsJSON), "avmapData is not correctly encoded")
 
+	decoded, err := DecodeActualValueMapData(s.fullReport.ActualValueMapData)
+	require.Nil(t, err, "error while decoding avMapData")
+	require.Equal(t, avGroupsTestData, decoded, "avmapData is not correctly decoded")
+
 	// check if ActualValueNodeMap is set to nil for each check
 	for _, gw := range s.fullReport.GroupWrappers {
 		for _, cw := range gw.CheckWrappers {
@@ -232,3 +241,256 @@ func getAvGroupTestData() ([]*ActualValueGroup, error) {
 
 	return testData, nil
 }
+
+const testBenchmark = "test-benchmark"
+
+// testTarget is a target of the test benchmark, holding one group whose ID
+// is the first two components of the check IDs.
+type testTarget struct {
+	name   string
+	checks []string
+	// nodeType is added to the target node type mapping when set.
+	nodeType string
+}
+
+// writeTestBenchmark writes the config.yaml and controls files of a benchmark
+// into a new controls directory.
+func writeTestBenchmark(t *testing.T, targets ...testTarget) string {
+	t.Helper()
+	dir := t.TempDir()
+	config := "version_mapping:\n  \"1.0\": " + testBenchmark + "\ntarget_mapping:\n  " + testBenchmark + ":\n"
+	require.Nil(t, os.MkdirAll(filepath.Join(dir, testBenchmark), 0o750))
+	for _, target := range targets {
+		config += "    - " + target.name + "\n"
+		controls := "controls:\nid: 1\ntext: " + target.name + "\ntype: " + target.name + "\ngroups:\n"
+		groups := map[string][]string{}
+		var groupIDs []string
+		for _, id := range target.checks {
+			group := id[:strings.LastIndex(id, ".")]
+			if _, ok := groups[group]; !ok {
+				groupIDs = append(groupIDs, group)
+			}
+			groups[group] = append(groups[group], id)
+		}
+		for _, group := range groupIDs {
+			controls += fmt.Sprintf("  - id: %q\n    text: group %s\n    checks:\n", group, group)
+			for _, id := range groups[group] {
+				controls += fmt.Sprintf("      - id: %q\n        text: check %s\n        audit: echo\n        scored: true\n", id, id)
+			}
+		}
+		require.Nil(t, os.WriteFile(filepath.Join(dir, testBenchmark, target.name+".yaml"), []byte(controls), 0o600))
+	}
+	mapping := ""
+	for _, target := range targets {
+		if target.nodeType != "" {
+			mapping += fmt.Sprintf("  %s: %s\n", target.name, target.nodeType)
+		}
+	}
+	if mapping != "" {
+		config += TargetNodeTypeMappingKey + ":\n" + mapping
+	}
+	require.Nil(t, os.WriteFile(filepath.Join(dir, ConfigFilename), []byte(config), 0o600))
+	return dir
+}
+
+// writeTestResults writes the kube-bench results of a target for a host.
+func writeTestResults(t *testing.T, inputDir, host, target string, states map[string]kb.State) {
+	t.Helper()
+	groups := map[string]*kb.Group{}
+	controls := &kb.Controls{ID: "1", Text: target}
+	ids := make([]string, 0, len(states))
+	for id := range states {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		groupID := id[:strings.LastIndex(id, ".")]
+		g, ok := groups[groupID]
+		if !ok {
+			g = &kb.Group{ID: groupID, Text: "group " + groupID}
+			groups[groupID] = g
+			controls.Groups = append(controls.Groups, g)
+		}
+		g.Checks = append(g.Checks, &kb.Check{ID: id, Text: "check " + id, State: states[id], ActualValue: host + "-" + string(states[id]), Scored: true})
+	}
+	data, err := json.Marshal(&kb.OverallControls{Controls: []*kb.Controls{controls}})
+	require.Nil(t, err)
+	require.Nil(t, os.MkdirAll(filepath.Join(inputDir, host), 0o750))
+	require.Nil(t, os.WriteFile(filepath.Join(inputDir, host, target+".json"), data, 0o600))
+}
+
+// summarizeTest runs the summarizer and returns the checks of the report by
+// ID.
+func summarizeTest(t *testing.T, s *Summarizer) map[string]*CheckWrapper {
+	t.Helper()
+	require.Nil(t, s.Summarize())
+	checks := map[string]*CheckWrapper{}
+	for _, gw := range s.fullReport.GroupWrappers {
+		for _, cw := range gw.CheckWrappers {
+			checks[cw.ID] = cw
+		}
+	}
+	return checks
+}
+
+func TestSummarizer_clusterChecks(t *testing.T) {
+	controlsDir := writeTestBenchmark(t,
+		testTarget{name: "master", checks: []string{"1.1.1"}},
+		testTarget{name: "node", checks: []string{"4.1.1"}},
+		testTarget{name: "policies", checks: []string{"5.1.1", "5.1.2", "5.1.3"}},
+	)
+	inputDir := t.TempDir()
+	for _, host := range []string{"m1", "m2", "m3"} {
+		writeTestResults(t, inputDir, host, "master", map[string]kb.State{"1.1.1": kb.PASS})
+		writeTestResults(t, inputDir, host, "node", map[string]kb.State{"4.1.1": kb.PASS})
+	}
+	// the policies target only ran on two of the masters
+	writeTestResults(t, inputDir, "m1", "policies", map[string]kb.State{"5.1.1": kb.PASS, "5.1.2": kb.FAIL, "5.1.3": kb.PASS})
+	writeTestResults(t, inputDir, "m2", "policies", map[string]kb.State{"5.1.1": kb.PASS, "5.1.2": kb.FAIL, "5.1.3": kb.FAIL})
+	writeTestResults(t, inputDir, "w1", "node", map[string]kb.State{"4.1.1": kb.PASS})
+
+	s, err := NewSummarizer("", testBenchmark, controlsDir, inputDir, t.TempDir(), DefaultOutputFileName, "", "", "", false)
+	require.Nil(t, err)
+	checks := summarizeTest(t, s)
+
+	assert.Equal(t, []string{ClusterNodeName}, s.fullReport.Nodes[NodeTypeCluster])
+	assert.ElementsMatch(t, []string{"m1", "m2", "m3"}, s.fullReport.Nodes[NodeTypeMaster])
+	assert.ElementsMatch(t, []string{"m1", "m2", "m3", "w1"}, s.fullReport.Nodes[NodeTypeNode])
+
+	assert.Equal(t, []NodeType{NodeTypeCluster}, checks["5.1.1"].NodeType)
+	assert.Equal(t, Pass, checks["5.1.1"].State)
+	assert.Empty(t, checks["5.1.1"].Nodes)
+	assert.Equal(t, Fail, checks["5.1.2"].State)
+	assert.Empty(t, checks["5.1.2"].Nodes)
+	// the masters disagree, the cluster has the least compliant state
+	assert.Equal(t, Fail, checks["5.1.3"].State)
+	assert.Empty(t, checks["5.1.3"].Nodes)
+	assert.Equal(t, map[string]kb.State{ClusterNodeName: kb.FAIL}, checks["5.1.3"].StateNodeMap)
+	assert.Equal(t, map[string]kb.State{"m1": kb.PASS, "m2": kb.FAIL}, checks["5.1.3"].HostStates)
+	assert.Nil(t, checks["5.1.2"].HostStates)
+
+	assert.Equal(t, Pass, checks["1.1.1"].State)
+	assert.Equal(t, Pass, checks["4.1.1"].State)
+	assert.Equal(t, 5, s.fullReport.Total)
+	assert.Equal(t, 3, s.fullReport.Pass)
+	assert.Equal(t, 2, s.fullReport.Fail)
+}
+
+func TestCollapseClusterResults(t *testing.T) {
+	cw := &CheckWrapper{
+		NodeType:           []NodeType{NodeTypeCluster},
+		Result:             map[kb.State]map[string]bool{kb.PASS: {"m1": true, "m2": true}},
+		ActualValueNodeMap: map[string]string{"m1": "true", "m2": "true"},
+	}
+	collapseClusterResults(cw)
+	assert.Equal(t, map[kb.State]map[string]bool{kb.PASS: {ClusterNodeName: true}}, cw.Result)
+	assert.Equal(t, map[string]string{ClusterNodeName: "true"}, cw.ActualValueNodeMap)
+
+	assert.Nil(t, cw.HostStates)
+
+	// the actual value is the one of the first master
+	cw.Result = map[kb.State]map[string]bool{kb.PASS: {"m1": true, "m2": true}}
+	cw.ActualValueNodeMap = map[string]string{"m1": "a", "m2": "b"}
+	collapseClusterResults(cw)
+	assert.Equal(t, map[kb.State]map[string]bool{kb.PASS: {ClusterNodeName: true}}, cw.Result)
+	assert.Equal(t, map[string]string{ClusterNodeName: "a"}, cw.ActualValueNodeMap)
+
+	// when the masters disagree, the least compliant state and its first
+	// master are kept
+	cw.Result = map[kb.State]map[string]bool{kb.PASS: {"m1": true}, kb.WARN: {"m2": true, "m3": true}, kb.INFO: {"m4": true}}
+	cw.ActualValueNodeMap = map[string]string{"m1": "a", "m2": "b", "m3": "c", "m4": "d"}
+	collapseClusterResults(cw)
+	assert.Equal(t, map[kb.State]map[string]bool{kb.WARN: {ClusterNodeName: true}}, cw.Result)
+	assert.Equal(t, map[string]kb.State{ClusterNodeName: kb.WARN}, cw.StateNodeMap)
+	assert.Equal(t, map[string]string{ClusterNodeName: "b"}, cw.ActualValueNodeMap)
+	assert.Equal(t, map[string]kb.State{"m1": kb.PASS, "m2": kb.WARN, "m3": kb.WARN, "m4": kb.INFO}, cw.HostStates)
+
+	// host scoped checks are left alone
+	cw = &CheckWrapper{
+		NodeType: []NodeType{NodeTypeMaster},
+		Result:   map[kb.State]map[string]bool{kb.PASS: {"m1": true}},
+	}
+	collapseClusterResults(cw)
+	assert.Equal(t, map[kb.State]map[string]bool{kb.PASS: {"m1": true}}, cw.Result)
+}
+
+func TestSummarizer_customTargets(t *testing.T) {
+	controlsDir := writeTestBenchmark(t,
+		testTarget{name: "master", checks: []string{"1.1.1"}, nodeType: FilePathNodeTypeMaster},
+		testTarget{name: "workers", checks: []string{"4.1.1"}, nodeType: FilePathNodeTypeNode},
+		testTarget{name: "rancher", checks: []string{"9.1.1"}},
+	)
+	inputDir := t.TempDir()
+	writeTestResults(t, inputDir, "m1", "master", map[string]kb.State{"1.1.1": kb.PASS})
+	writeTestResults(t, inputDir, "m1", "rancher", map[string]kb.State{"9.1.1": kb.FAIL})
+	writeTestResults(t, inputDir, "w1", "workers", map[string]kb.State{"4.1.1": kb.PASS})
+	writeTestResults(t, inputDir, "w2", "workers", map[string]kb.State{"4.1.1": kb.FAIL})
+	// not a target of the benchmark, ignored
+	writeTestResults(t, inputDir, "w1", "policies", map[string]kb.State{"5.1.1": kb.FAIL})
+
+	s, err := NewSummarizer("", testBenchmark, controlsDir, inputDir, t.TempDir(), DefaultOutputFileName, "", "", "", false)
+	require.Nil(t, err)
+	checks := summarizeTest(t, s)
+
+	assert.Equal(t, map[NodeType][]string{
+		NodeTypeMaster:  {"m1"},
+		NodeTypeNode:    {"w1", "w2"},
+		NodeTypeCluster: {ClusterNodeName},
+	}, s.fullReport.Nodes)
+	assert.Equal(t, []NodeType{NodeTypeNode}, checks["4.1.1"].NodeType)
+	assert.Equal(t, Mixed, checks["4.1.1"].State)
+	assert.Equal(t, []NodeType{NodeTypeCluster}, checks["9.1.1"].NodeType)
+	assert.Equal(t, Fail, checks["9.1.1"].State)
+	assert.Equal(t, 3, s.fullReport.Total)
+}
+
+func TestSummarizer_loadTargetNodeTypeMapping(t *testing.T) {
+	controlsDir := writeTestBenchmark(t, testTarget{name: "master", checks: []string{"1.1.1"}, nodeType: "controlplane"})
+	_, err := NewSummarizer("", testBenchmark, controlsDir, t.TempDir(), t.TempDir(), DefaultOutputFileName, "", "", "", false)
+	assert.NotNil(t, err)
+
+	// without a mapping the historical targets keep their node type
+	controlsDir = writeTestBenchmark(t, testTarget{name: "etcd", checks: []string{"2.1"}})
+	s, err := NewSummarizer("", testBenchmark, controlsDir, t.TempDir(), t.TempDir(), DefaultOutputFileName, "", "", "", false)
+	require.Nil(t, err)
+	assert.Equal(t, NodeTypeEtcd, s.getTargetNodeType("etcd"))
+	assert.Equal(t, NodeTypeCluster, s.getTargetNodeType("policies"))
+	nodeType, ok := s.getResultsFileNodeType("controlplane.json")
+	assert.True(t, ok)
+	assert.Equal(t, NodeTypeCluster, nodeType)
+}
+
+// writeTestFile writes a file into a new directory and returns its path.
+func writeTestFile(t *testing.T, name, content string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), name)
+	require.Nil(t, os.WriteFile(p, []byte(content), 0o600))
+	return p
+}
+
+func TestSummarizer_provenance(t *testing.T) {
+	controlsDir := writeTestBenchmark(t, testTarget{name: "master", checks: []string{"1.1.1", "1.1.2", "1.1.3", "1.1.4"}})
+	inputDir := t.TempDir()
+	writeTestResults(t, inputDir, "m1", "master", map[string]kb.State{"1.1.1": kb.FAIL, "1.1.2": kb.FAIL, "1.1.3": kb.FAIL, "1.1.4": kb.PASS})
+	userSkip := writeTestFile(t, "user-skip.json", `{"skip": {"`+testBenchmark+`": ["1.1.1", "1.1.3"]}}`)
+	defaultSkip := writeTestFile(t, "default-skip.json", `{"1.1.2": "handled by the platform"}`)
+	notApplicable := writeTestFile(t, "not-applicable.json", `{"1.1.3": "no such component"}`)
+
+	s, err := NewSummarizer("", testBenchmark, controlsDir, inputDir, t.TempDir(), DefaultOutputFileName, userSkip, defaultSkip, notApplicable, false)
+	require.Nil(t, err)
+	checks := summarizeTest(t, s)
+
+	assert.Equal(t, Skip, checks["1.1.1"].State)
+	assert.Equal(t, ProvenanceUserSkip, checks["1.1.1"].Provenance)
+	assert.Equal(t, UserSkipReason, checks["1.1.1"].ProvenanceReason)
+	assert.Equal(t, Skip, checks["1.1.2"].State)
+	assert.Equal(t, ProvenanceDefaultSkip, checks["1.1.2"].Provenance)
+	assert.Equal(t, "handled by the platform", checks["1.1.2"].ProvenanceReason)
+	// not applicable takes precedence over the user skip
+	assert.Equal(t, NotApplicable, checks["1.1.3"].State)
+	assert.Equal(t, ProvenanceNotApplicable, checks["1.1.3"].Provenance)
+	assert.Equal(t, "no such component", checks["1.1.3"].ProvenanceReason)
+	assert.Equal(t, Pass, checks["1.1.4"].State)
+	assert.Equal(t, ProvenanceNone, checks["1.1.4"].Provenance)
+	assert.Empty(t, checks["1.1.4"].ProvenanceReason)
+}